@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"github.com/glassonion1/logz"
 	"github.com/kelseyhightower/envconfig"
 	"golang.org/x/xerrors"
 	"sync"
+	"time"
 )
 
 const (
@@ -13,6 +16,15 @@ const (
 	ENV_DEVELOPMENT = "development"
 	ENV_PRODUCTION  = "production"
 	APP_ENV         = "APP_ENV"
+
+	// Values of DB_DRIVER
+	DB_DRIVER_MYSQL    = "mysql"
+	DB_DRIVER_POSTGRES = "postgres"
+	DB_DRIVER_SQLITE   = "sqlite"
+
+	// Values of TODO_STORE
+	TODO_STORE_DATABASE = "database"
+	TODO_STORE_MEMORY   = "memory"
 )
 
 type (
@@ -28,32 +40,72 @@ type (
 		ProjectUuid string `required:"true" envconfig:"PROJECT_UUID" default:""`
 		ProjectId   string `required:"true" envconfig:"PROJECT_ID" default:""`
 		ImageName   string `required:"true" envconfig:"IMAGE_NAME" default:"go-cloudrun-boilerplate"`
-		Port        int    `required:"false" envconfig:"PORT" default:1323`
+		Port        int    `required:"false" envconfig:"PORT" default:"1323"`
 
 		// Database
+		// mysql, postgres, or sqlite to keep the database in the file DB_NAME.db.
+		// Set DB_PORT to 5432 for postgres.
+		DBDriver     string `required:"false" envconfig:"DB_DRIVER" default:"mysql"`
 		DBIP         string `required:"false" envconfig:"DB_IP" default:"127.0.0.1"`
 		DBPort       int64  `required:"true" envconfig:"DB_PORT" default:"3306"`
 		MaxIdleConns int    `required:"false" envconfig:"DB_MAX_IDLE_CONNS" default:"10"`
 		MaxOpenConns int    `required:"false" envconfig:"DB_MAX_OPEN_CONNS" default:"100"`
+		TxIsolation  string `required:"false" envconfig:"DB_TX_ISOLATION" default:"REPEATABLE READ"`
+		TxMaxRetries int    `required:"false" envconfig:"DB_TX_MAX_RETRIES" default:"3"`
+		// Directory with a subdirectory of migrations per DB_DRIVER to use instead of
+		// the ones embedded in the binary, for development
+		MigrationsPath string `required:"false" envconfig:"MIGRATIONS_PATH" default:""`
+		// Apply pending migrations before serving. Instances starting together wait
+		// up to DB_MIGRATION_LOCK_TIMEOUT seconds for the lock, then as long again
+		// for the one holding it to finish.
+		MigrateOnStartup     bool `required:"false" envconfig:"DB_MIGRATE_ON_STARTUP" default:"false"`
+		MigrationLockTimeout int  `required:"false" envconfig:"DB_MIGRATION_LOCK_TIMEOUT" default:"60"`
 
 		// GCS
 		BucketName string `required:"false" envconfig:"BUCKET_NAME" default:"go-cloudrun-boilerplate-us-central1-data"`
 		ObjectName string `required:"false" envconfig:"OBJECT_NAME" default:"test.json"`
 
 		// Instance related
+		// Seconds, the request timeout of the Cloud Run service. It bounds the server
+		// reads and writes, and the routes which may take long such as batches.
 		TimeOut int `required:"false" envconfig:"TIMEOUT" default:"1200"`
+		// Seconds a request may take on the other routes, and to keep an idle connection
+		RequestTimeout int `required:"false" envconfig:"REQUEST_TIMEOUT" default:"60"`
+		IdleTimeout    int `required:"false" envconfig:"IDLE_TIMEOUT" default:"120"`
+		// Seconds to wait for in-flight requests after SIGTERM.
+		// Cloud Run kills the instance 10 seconds after sending it.
+		ShutdownGracePeriod int `required:"false" envconfig:"SHUTDOWN_GRACE_PERIOD" default:"8"`
+		// Seconds each dependency check of /readyz may take, and to reuse its report
+		HealthCheckTimeout int `required:"false" envconfig:"HEALTH_CHECK_TIMEOUT" default:"2"`
+		HealthCacheTTL     int `required:"false" envconfig:"HEALTH_CACHE_TTL" default:"5"`
+
+		// Todo
+		// database to keep todos in the database of DB_DRIVER,
+		// or memory to keep them in the process without any database
+		TodoStore          string `required:"false" envconfig:"TODO_STORE" default:"database"`
+		TrashRetentionDays int    `required:"false" envconfig:"TRASH_RETENTION_DAYS" default:"30"`
+		BatchMaxSize       int    `required:"false" envconfig:"BATCH_MAX_SIZE" default:"100"`
+		// Serve the paths before /v1 with the Deprecation and Sunset headers,
+		// turn them off once the sunset date has passed
+		LegacyRoutes       bool      `required:"false" envconfig:"LEGACY_ROUTES" default:"true"`
+		LegacyRoutesSunset time.Time `required:"false" envconfig:"LEGACY_ROUTES_SUNSET" default:"2027-04-17T00:00:00Z"`
 
 		// Secrets
 		UserName         string `required:"true" envconfig:"DB_USERNAME" default:"root"`
 		Password         string `required:"true" envconfig:"DB_PASSWORD" default:"admin"`
 		CloudSQLInstance string `required:"false" envconfig:"CLOUDSQL_INSTANCES" default:""`
 		Name             string `required:"true" envconfig:"DB_NAME" default:"test"`
+		// A random key of the process when empty, see GetApplicationConfig
+		CursorSecret string `required:"false" envconfig:"CURSOR_SECRET" default:""`
 	}
 )
 
 var (
 	appConfig *applicationConfig
 	once      sync.Once
+
+	ErrUnknownDBDriver = xerrors.New("unknown DB_DRIVER, use mysql, postgres or sqlite")
+	ErrNoCursorSecret  = xerrors.New("no CURSOR_SECRET, create the secret <IMAGE_NAME>-CURSOR_SECRET in Secret Manager")
 )
 
 func GetApplicationConfig(ctx context.Context) *applicationConfig {
@@ -94,11 +146,53 @@ func GetApplicationConfig(ctx context.Context) *applicationConfig {
 			if err != nil {
 				logz.Criticalf(ctx, "%+v\n", xerrors.Errorf("DB_PASSWORD : %+w\n", err))
 			}
+
+			appConfig.CursorSecret, err = secret.GetSecret(appConfig.ImageName + "-CURSOR_SECRET")
+			if err != nil {
+				logz.Criticalf(ctx, "%+v\n", xerrors.Errorf("CURSOR_SECRET : %+w\n", err))
+			}
+		}
+
+		// Cursors signed with a key in the source could be forged by anyone.
+		// Production requires the secret, see Validate.
+		if appConfig.CursorSecret == "" && !appConfig.IsProduction() {
+			if !appConfig.IsTest() {
+				logz.Warningf(ctx, "No CURSOR_SECRET, the cursors are signed with a random key and only work within this instance until it restarts")
+			}
+			appConfig.CursorSecret = randomSecret()
+		}
+
+		if err := appConfig.Validate(); err != nil {
+			logz.Criticalf(ctx, "%+v\n", err)
 		}
 	})
 	return appConfig
 }
 
+// Check the values which have no usable fallback, so that startup fails
+// rather than running with a setting the operator did not mean
+func (conf *applicationConfig) Validate() error {
+	switch conf.DBDriver {
+	case DB_DRIVER_MYSQL, DB_DRIVER_POSTGRES, DB_DRIVER_SQLITE:
+	default:
+		return xerrors.Errorf("Validate : DB_DRIVER=%s : %+w", conf.DBDriver, ErrUnknownDBDriver)
+	}
+	// A random key per instance would fail the cursors of the other instances
+	if conf.IsProduction() && conf.CursorSecret == "" {
+		return xerrors.Errorf("Validate : %+w", ErrNoCursorSecret)
+	}
+	return nil
+}
+
+// 256 bits from crypto/rand
+func randomSecret() string {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(xerrors.Errorf("randomSecret : %+w", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
 // Test if the environment is test
 func (conf *applicationConfig) IsTest() bool {
 	if conf.Environment == ENV_TEST {
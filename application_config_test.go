@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
 	"testing"
 )
 
@@ -16,5 +17,26 @@ func TestApplicationConfig(t *testing.T) {
 
 		assert.NotNil(t, config)
 		assert.NotEmpty(t, config.ProjectId)
+		// A random key rather than one anyone can read in the source
+		assert.NotEmpty(t, config.CursorSecret)
+	})
+	t.Run("Validate", func(t *testing.T) {
+		t.Parallel()
+
+		config := *GetApplicationConfig(ctx)
+		assert.Nil(t, config.Validate())
+
+		// Typos do not fall back to MySQL
+		config.DBDriver = "postgresql"
+		assert.True(t, xerrors.Is(config.Validate(), ErrUnknownDBDriver))
+
+		_, err := (&cloudSQL{config: &config}).Open(ctx, "")
+		assert.True(t, xerrors.Is(err, ErrUnknownDBDriver))
+
+		// Production has no random fallback for the cursor secret
+		config = *GetApplicationConfig(ctx)
+		config.Environment = ENV_PRODUCTION
+		config.CursorSecret = ""
+		assert.True(t, xerrors.Is(config.Validate(), ErrNoCursorSecret))
 	})
 }
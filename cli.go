@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/glassonion1/logz"
+	"golang.org/x/xerrors"
+	"io"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Exit codes of runCLI
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+
+	maskedSecret = "********"
+)
+
+var (
+	ErrUsage = xerrors.New("invalid usage")
+)
+
+type (
+	command struct {
+		name  string
+		usage string
+		run   func(ctx context.Context, args []string, out io.Writer) error
+	}
+)
+
+// Subcommands of the binary. serve runs when none is given,
+// which is how Cloud Run starts the container.
+func commands() []*command {
+	return []*command{
+		{name: "serve", usage: "serve", run: runServe},
+		{name: "migrate", usage: "migrate up|down|status|to <version>|force <version>", run: runMigrate},
+		{name: "seed", usage: "seed [-n <count>]", run: runSeed},
+		{name: "purge-trash", usage: "purge-trash", run: runPurgeTrash},
+		{name: "config", usage: "config print", run: runConfig},
+		{name: "healthcheck", usage: "healthcheck", run: runHealthcheck},
+	}
+}
+
+// Run the subcommand of args and return the exit code
+func runCLI(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	name := "serve"
+	if 0 < len(args) {
+		name, args = args[0], args[1:]
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name != name {
+			continue
+		}
+
+		// Fail before connecting with a DB_DRIVER which would fall back to another database
+		if err := GetApplicationConfig(ctx).Validate(); err != nil {
+			fmt.Fprintf(stderr, "%s : %+v\n", name, err)
+			return exitError
+		}
+
+		err := cmd.run(ctx, args, stdout)
+		if xerrors.Is(err, ErrUsage) {
+			fmt.Fprintf(stderr, "%v\nusage: %s\n", err, cmd.usage)
+			return exitUsage
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "%s : %+v\n", name, err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	fmt.Fprintf(stderr, "unknown command %s\n", name)
+	printUsage(stderr)
+	return exitUsage
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %s\n", cmd.usage)
+	}
+}
+
+func runServe(ctx context.Context, args []string, out io.Writer) error {
+	if 0 < len(args) {
+		return xerrors.Errorf("serve takes no arguments : %w", ErrUsage)
+	}
+	return serve(ctx)
+}
+
+// Migrations of DB_DRIVER, run once to the end. Cloud Run jobs use it before a deploy.
+func runMigrate(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return xerrors.Errorf("no migrate subcommand : %w", ErrUsage)
+	}
+
+	// Parsed before connecting so that usage errors need no database
+	var version int
+	switch args[0] {
+	case "up", "down", "status":
+		if len(args) != 1 {
+			return xerrors.Errorf("migrate %s takes no arguments : %w", args[0], ErrUsage)
+		}
+	case "to", "force":
+		if len(args) != 2 {
+			return xerrors.Errorf("migrate %s takes a version : %w", args[0], ErrUsage)
+		}
+		v, err := strconv.Atoi(args[1])
+		// Only force takes -1, which means no version
+		if err != nil || v < -1 || (v < 0 && args[0] == "to") {
+			return xerrors.Errorf("invalid version %s : %w", args[1], ErrUsage)
+		}
+		version = v
+	default:
+		return xerrors.Errorf("unknown migrate subcommand %s : %w", args[0], ErrUsage)
+	}
+
+	cloudSQL, err := NewCloudSQL(ctx)
+	if err != nil {
+		return xerrors.Errorf("runMigrate : %+w", err)
+	}
+	defer closeCloudSQL(ctx, cloudSQL)
+
+	switch args[0] {
+	case "up":
+		return cloudSQL.MigrateUp(ctx)
+	case "down":
+		// One step like RollbackLastMigrations, there is no undo
+		return cloudSQL.RollbackLastMigrations(ctx)
+	case "to":
+		return cloudSQL.MigrateTo(ctx, uint(version))
+	case "force":
+		return cloudSQL.ForceVersion(ctx, version)
+	}
+
+	status, err := cloudSQL.MigrationStatus(ctx)
+	if err != nil {
+		return xerrors.Errorf("runMigrate : %+w", err)
+	}
+	return printJSON(out, status)
+}
+
+// Fill the todo store with random todos for development
+func runSeed(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	count := flags.Int("n", 10, "number of todos")
+	if err := flags.Parse(args); err != nil || *count < 1 || 0 < flags.NArg() {
+		return xerrors.Errorf("invalid seed arguments : %w", ErrUsage)
+	}
+
+	c := &components{}
+	todoService, err := newTodoService(ctx, c)
+	if err != nil {
+		return xerrors.Errorf("runSeed : %+w", err)
+	}
+	defer closeComponents(ctx, c)
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for i := 0; i < *count; i++ {
+		if _, err := todoService.Create(ctx, &Todo{Task: seedTask(random)}); err != nil {
+			return xerrors.Errorf("runSeed : %+w", err)
+		}
+	}
+
+	fmt.Fprintf(out, "created %d todos\n", *count)
+	return nil
+}
+
+// Task such as "Buy milk before the meeting" built from a few words, so that
+// the seeded todos can be searched
+func seedTask(random *rand.Rand) string {
+	verbs := []string{"Buy", "Call", "Clean", "Fix", "Read", "Write", "Send", "Book"}
+	objects := []string{"milk", "the report", "the car", "a birthday card", "the invoice", "the dentist", "the garden", "the slides"}
+	whens := []string{"today", "tomorrow", "before the meeting", "this weekend", "after lunch", "next week"}
+
+	return fmt.Sprintf("%s %s %s",
+		verbs[random.Intn(len(verbs))], objects[random.Intn(len(objects))], whens[random.Intn(len(whens))])
+}
+
+// Hard delete the trashed todos older than TRASH_RETENTION_DAYS. It is not served over HTTP,
+// a Cloud Run job or Cloud Scheduler runs it with the credentials of the project.
+func runPurgeTrash(ctx context.Context, args []string, out io.Writer) error {
+	if 0 < len(args) {
+		return xerrors.Errorf("purge-trash takes no arguments : %w", ErrUsage)
+	}
+
+	retention := time.Duration(GetApplicationConfig(ctx).TrashRetentionDays) * 24 * time.Hour
+	c := &components{}
+	todoService, err := newTodoService(ctx, c)
+	if err != nil {
+		return xerrors.Errorf("runPurgeTrash : %+w", err)
+	}
+	defer closeComponents(ctx, c)
+
+	rowsAffected, err := todoService.PurgeTrash(ctx, retention)
+	if err != nil {
+		return xerrors.Errorf("runPurgeTrash : %+w", err)
+	}
+
+	fmt.Fprintf(out, "purged %d todos\n", rowsAffected)
+	return nil
+}
+
+// Print the config in effect with the secrets masked
+func runConfig(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) != 1 || args[0] != "print" {
+		return xerrors.Errorf("unknown config subcommand %s : %w", strings.Join(args, " "), ErrUsage)
+	}
+
+	config := *GetApplicationConfig(ctx)
+	for _, secret := range []*string{&config.Password, &config.CursorSecret} {
+		if *secret != "" {
+			*secret = maskedSecret
+		}
+	}
+	return printJSON(out, configByEnv(&config))
+}
+
+// Values of the config keyed by the environment variables operators set, such as IMAGE_NAME
+func configByEnv(config *applicationConfig) map[string]interface{} {
+	values := map[string]interface{}{}
+	v := reflect.ValueOf(config).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Type().Field(i).Tag.Get("envconfig"); name != "" {
+			values[name] = v.Field(i).Interface()
+		}
+	}
+	return values
+}
+
+// Exit with 1 when the database can not be reached
+func runHealthcheck(ctx context.Context, args []string, out io.Writer) error {
+	if 0 < len(args) {
+		return xerrors.Errorf("healthcheck takes no arguments : %w", ErrUsage)
+	}
+	if GetApplicationConfig(ctx).TodoStore == TODO_STORE_MEMORY {
+		fmt.Fprintln(out, "ok")
+		return nil
+	}
+
+	cloudSQL, err := NewCloudSQL(ctx)
+	if err != nil {
+		return xerrors.Errorf("runHealthcheck : %+w", err)
+	}
+	defer closeCloudSQL(ctx, cloudSQL)
+
+	if err := cloudSQL.Ping(ctx); err != nil {
+		return xerrors.Errorf("runHealthcheck : %+w", err)
+	}
+
+	fmt.Fprintln(out, "ok")
+	return nil
+}
+
+// Release the pool a command has opened
+func closeCloudSQL(ctx context.Context, cloudSQL CloudSQL) {
+	if err := cloudSQL.Close(); err != nil {
+		logz.Errorf(ctx, "Failed to close the database. %+v\n", err)
+	}
+}
+
+// Release the repository newTodoService has built, there is none in memory mode
+func closeComponents(ctx context.Context, c *components) {
+	if c.repository == nil {
+		return
+	}
+	if err := c.repository.Close(); err != nil {
+		logz.Errorf(ctx, "Failed to close the repository. %+v\n", err)
+	}
+}
+
+func printJSON(out io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCLI(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+
+	run := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI(ctx, args, &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	t.Run("Usage errors", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"unknown"},
+			{"serve", "now"},
+			{"migrate"},
+			{"migrate", "sideways"},
+			{"migrate", "up", "2"},
+			{"migrate", "to", "-1"},
+			{"migrate", "force", "x"},
+			{"seed", "-n", "0"},
+			{"purge-trash", "now"},
+			{"config"},
+			{"healthcheck", "now"},
+		} {
+			code, _, stderr := run(args...)
+			assert.Equal(t, exitUsage, code, args)
+			assert.Contains(t, stderr, "usage", args)
+		}
+	})
+
+	t.Run("Config print masks secrets", func(t *testing.T) {
+		code, stdout, _ := run("config", "print")
+		assert.Equal(t, exitOK, code)
+
+		printed := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal([]byte(stdout), &printed))
+		assert.Equal(t, GetApplicationConfig(ctx).ImageName, printed["IMAGE_NAME"])
+		assert.Equal(t, maskedSecret, printed["DB_PASSWORD"])
+		assert.Equal(t, maskedSecret, printed["CURSOR_SECRET"])
+		assert.NotContains(t, printed, "ImageName")
+	})
+
+	t.Run("Healthcheck", func(t *testing.T) {
+		code, stdout, _ := run("healthcheck")
+		assert.Equal(t, exitOK, code)
+		assert.Equal(t, "ok\n", stdout)
+	})
+
+	t.Run("Seed", eachTestWrapper(func(t *testing.T) {
+		code, stdout, _ := run("seed", "-n", "3")
+		assert.Equal(t, exitOK, code)
+		assert.Equal(t, "created 3 todos\n", stdout)
+
+		_, rows, err := newTestTodoService(t, ctx).List(ctx, nil, 1, 20, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, rows)
+	}))
+
+	t.Run("Purge trash", eachTestWrapper(func(t *testing.T) {
+		todoService := newTestTodoService(t, ctx)
+		createdTodo, err := todoService.Create(ctx, &Todo{Task: "test task"})
+		assert.Nil(t, err)
+		_, err = todoService.Delete(ctx, createdTodo.ID)
+		assert.Nil(t, err)
+
+		// Nothing is older than the retention
+		code, stdout, _ := run("purge-trash")
+		assert.Equal(t, exitOK, code)
+		assert.Equal(t, "purged 0 todos\n", stdout)
+
+		_, rows, err := todoService.ListTrash(ctx, 1, 10)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, rows)
+	}))
+
+	t.Run("Migrate status", eachTestWrapper(func(t *testing.T) {
+		skipWithoutDatabase(t)
+
+		code, stdout, _ := run("migrate", "status")
+		assert.Equal(t, exitOK, code)
+
+		status := &MigrationStatus{}
+		assert.Nil(t, json.Unmarshal([]byte(stdout), status))
+		assert.Equal(t, &MigrationStatus{Version: 5, Pending: []uint{}}, status)
+	}))
+}
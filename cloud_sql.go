@@ -2,14 +2,16 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"github.com/glassonion1/logz"
+	"github.com/glebarez/sqlite"
 	"github.com/golang-migrate/migrate"
-	golang_migrate_mysql "github.com/golang-migrate/migrate/database/mysql"
-	_ "github.com/golang-migrate/migrate/source/file"
 	"golang.org/x/xerrors"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"strings"
 	"sync"
 	"time"
 )
@@ -17,12 +19,26 @@ import (
 type (
 	CloudSQL interface {
 		GetDSN() string
+		// Value of DB_DRIVER, which decides the SQL dialect
+		Driver() string
 		Open(ctx context.Context, dsn string) (*gorm.DB, error)
-		DB() *gorm.DB
+		DB(ctx context.Context) *gorm.DB
+		Ping(ctx context.Context) error
+		Close() error
+		WithTx(ctx context.Context, fn func(tx CloudSQL) error, opts ...TxOption) error
 		GenerateDSNLocal(name string, username string, password string, ip string, port int64) string
 		GenerateDSNForCloudDB(name string, username string, password string, cloudSqlInstances string) string
+		GenerateDSNPostgresLocal(name string, username string, password string, ip string, port int64) string
+		GenerateDSNPostgresForCloudDB(name string, username string, password string, cloudSqlInstances string) string
+		GenerateDSNSQLite(name string) string
 		StartMigrations(ctx context.Context) error
 		RollbackLastMigrations(ctx context.Context) error
+		MigrateUp(ctx context.Context) error
+		MigrateUpLocked(ctx context.Context, timeout time.Duration) error
+		MigrateTo(ctx context.Context, version uint) error
+		MigrationStatus(ctx context.Context) (*MigrationStatus, error)
+		SchemaVersion(ctx context.Context) (uint, bool, error)
+		ForceVersion(ctx context.Context, version int) error
 	}
 
 	cloudSQL struct {
@@ -30,18 +46,31 @@ type (
 		dsn    string
 		db     *gorm.DB
 		config *applicationConfig
+		// Default isolation level of WithTx
+		isolation sql.IsolationLevel
 	}
 )
 
 // SQL Connection
 // https://github.com/terraform-google-modules/terraform-google-sql-db/tree/master/modules/safer_mysql
-func NewCloudSQL(ctx context.Context) CloudSQL {
+func NewCloudSQL(ctx context.Context) (CloudSQL, error) {
 	c := &cloudSQL{}
 
 	c.config = GetApplicationConfig(ctx)
 
+	isolation, err := parseIsolationLevel(c.config.TxIsolation)
+	if err != nil {
+		logz.Errorf(ctx, "Falling back to the default isolation level. %+v\n", xerrors.Errorf(": %+w", err))
+	}
+	c.isolation = isolation
+
 	// Build DSN to access the database
-	if c.config.IsProduction() || c.config.IsDevelopment() {
+	if c.config.DBDriver == DB_DRIVER_SQLITE {
+		// Local file in every environment
+		c.dsn = c.GenerateDSNSQLite(c.config.Name)
+	} else if c.config.DBDriver == DB_DRIVER_POSTGRES {
+		c.dsn = c.generateDSNPostgres()
+	} else if c.config.IsProduction() || c.config.IsDevelopment() {
 		// Production or Development
 		c.dsn = c.GenerateDSNForCloudDB(
 			c.config.Name,
@@ -62,19 +91,23 @@ func NewCloudSQL(ctx context.Context) CloudSQL {
 
 	db, err := c.Open(ctx, c.dsn)
 	if err != nil {
-		logz.Criticalf(ctx, "Failed to open database connection. %+v\n", xerrors.Errorf(": %+w", err))
+		return nil, xerrors.Errorf("NewCloudSQL : failed to open database connection : %+w", err)
 	}
 
 	// Set DB
 	c.db = db
 
-	return c
+	return c, nil
 }
 
 func (c *cloudSQL) GetDSN() string {
 	return c.dsn
 }
 
+func (c *cloudSQL) Driver() string {
+	return c.config.DBDriver
+}
+
 func (c *cloudSQL) GenerateDSNLocal(name string, username string, password string, ip string, port int64) string {
 	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local", username, password, ip, port, name)
 }
@@ -83,8 +116,63 @@ func (c *cloudSQL) GenerateDSNForCloudDB(name string, username string, password
 	return fmt.Sprintf("%s:%s@unix(/cloudsql/%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", username, password, cloudSqlInstances, name)
 }
 
+func (c *cloudSQL) generateDSNPostgres() string {
+	if c.config.IsProduction() || c.config.IsDevelopment() {
+		return c.GenerateDSNPostgresForCloudDB(
+			c.config.Name,
+			c.config.UserName,
+			c.config.Password,
+			c.config.CloudSQLInstance,
+		)
+	}
+	return c.GenerateDSNPostgresLocal(
+		c.config.Name,
+		c.config.UserName,
+		c.config.Password,
+		c.config.DBIP,
+		c.config.DBPort,
+	)
+}
+
+// Key value DSN, timestamps are read and written in UTC like on MySQL
+// https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-CONNSTRING
+func (c *cloudSQL) GenerateDSNPostgresLocal(name string, username string, password string, ip string, port int64) string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable TimeZone=UTC",
+		pgQuote(ip), port, pgQuote(username), pgQuote(password), pgQuote(name))
+}
+
+// Cloud SQL for PostgreSQL listens on /cloudsql/<INSTANCE_CONNECTION_NAME>/.s.PGSQL.5432
+// https://cloud.google.com/sql/docs/postgres/connect-run
+func (c *cloudSQL) GenerateDSNPostgresForCloudDB(name string, username string, password string, cloudSqlInstances string) string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=UTC",
+		pgQuote("/cloudsql/"+cloudSqlInstances), pgQuote(username), pgQuote(password), pgQuote(name))
+}
+
+// Quote a key value DSN value so that spaces and quotes in passwords survive
+func pgQuote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// Pure Go SQLite, WAL lets readers run while a transaction writes.
+// https://pkg.go.dev/modernc.org/sqlite
+func (c *cloudSQL) GenerateDSNSQLite(name string) string {
+	return fmt.Sprintf("file:%s.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite", name)
+}
+
 func (c *cloudSQL) Open(ctx context.Context, dsn string) (*gorm.DB, error) {
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+	var dialector gorm.Dialector
+	switch c.config.DBDriver {
+	case DB_DRIVER_POSTGRES:
+		dialector = postgres.Open(dsn)
+	case DB_DRIVER_SQLITE:
+		dialector = sqlite.Open(dsn)
+	case DB_DRIVER_MYSQL:
+		dialector = mysql.Open(dsn)
+	default:
+		return nil, xerrors.Errorf("Open : DB_DRIVER=%s : %+w", c.config.DBDriver, ErrUnknownDBDriver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
 		// Caches Prepared Statement
 		// https://gorm.io/docs/performance.html#Caches-Prepared-Statement
 		PrepareStmt: true,
@@ -97,9 +185,6 @@ func (c *cloudSQL) Open(ctx context.Context, dsn string) (*gorm.DB, error) {
 		return nil, xerrors.Errorf(": %+w", err)
 	}
 
-	// Set Context
-	db.WithContext(ctx)
-
 	// GetDomains generic database object sql.DB to use its functions
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -118,96 +203,74 @@ func (c *cloudSQL) Open(ctx context.Context, dsn string) (*gorm.DB, error) {
 	return db, nil
 }
 
-// Database handler
-func (c *cloudSQL) DB() *gorm.DB {
-	return c.db
+// Database handler running queries with the context,
+// so that they are canceled with the request
+// https://gorm.io/docs/context.html
+func (c *cloudSQL) DB(ctx context.Context) *gorm.DB {
+	return c.db.WithContext(ctx)
 }
 
-// https://github.dev/elsennov/guitar_collection/blob/1f869cd16ddeab778c42fa54d72cba5bdd870305/console/migrations.go
-func (c *cloudSQL) StartMigrations(ctx context.Context) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// Check the database can be reached
+func (c *cloudSQL) Ping(ctx context.Context) error {
+	if c.db == nil {
+		return xerrors.Errorf("Ping : database connection is not open")
+	}
 
-	db, err := c.DB().DB()
+	db, err := c.db.DB()
 	if err != nil {
 		return xerrors.Errorf("Error db.DB() : %+w", err)
 	}
-
-	if err := db.Ping(); err != nil {
-		return xerrors.Errorf("could not ping DB...  : %+w", err)
+	if err := db.PingContext(ctx); err != nil {
+		return xerrors.Errorf("Ping : %+w", err)
 	}
+	return nil
+}
 
-	driver, err := golang_migrate_mysql.WithInstance(db, &golang_migrate_mysql.Config{})
-	if err != nil {
-		xerr := xerrors.Errorf("Error mysql.WithInstance : %+w", err)
-		logz.Errorf(ctx, " %+v", xerr)
-		return xerr
+// Close the connection pool, queries in flight are waited for
+func (c *cloudSQL) Close() error {
+	if c.db == nil {
+		return nil
 	}
 
-	migration, err := migrate.NewWithDatabaseInstance(
-		"file://migrations",
-		c.config.Name,
-		driver,
-	)
+	db, err := c.db.DB()
 	if err != nil {
-		xerr := xerrors.Errorf("Error migrate.NewWithDatabaseInstance : %+w", err)
-		logz.Errorf(ctx, " %+v", xerr)
-		return xerr
+		return xerrors.Errorf("Error db.DB() : %+w", err)
 	}
-
-	if migration != nil {
-		err := migration.Steps(1)
-		if err != nil {
-			xerr := xerrors.Errorf(": %+w", err)
-			logz.Errorf(ctx, " %+v", xerr)
-			return xerr
-		}
+	if err := db.Close(); err != nil {
+		return xerrors.Errorf("Close : %+w", err)
 	}
 	return nil
 }
 
-func (c *cloudSQL) RollbackLastMigrations(ctx context.Context) error {
+// Apply the next migration
+// https://github.dev/elsennov/guitar_collection/blob/1f869cd16ddeab778c42fa54d72cba5bdd870305/console/migrations.go
+func (c *cloudSQL) StartMigrations(ctx context.Context) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	db, err := c.DB().DB()
+	err := c.withMigrate(ctx, func(m *migrate.Migrate) error {
+		return m.Steps(1)
+	})
 	if err != nil {
-		xerr := xerrors.Errorf("Error db.DB() : %+w", err)
-		logz.Errorf(ctx, " %+v", xerr)
-		return xerr
-	}
-
-	if err := db.Ping(); err != nil {
-		xerr := xerrors.Errorf("could not ping DB...  : %+w", err)
+		xerr := xerrors.Errorf(": %+w", err)
 		logz.Errorf(ctx, " %+v", xerr)
 		return xerr
 	}
+	return nil
+}
 
-	driver, err := golang_migrate_mysql.WithInstance(db, &golang_migrate_mysql.Config{})
-	if err != nil {
-		xerr := xerrors.Errorf("Error mysql.WithInstance : %+w", err)
-		logz.Errorf(ctx, " %+v", xerr)
-		return xerr
-	}
+// Roll the last applied migration back
+func (c *cloudSQL) RollbackLastMigrations(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	migration, err := migrate.NewWithDatabaseInstance(
-		"file://migrations",
-		c.config.Name,
-		driver,
-	)
+	err := c.withMigrate(ctx, func(m *migrate.Migrate) error {
+		return m.Steps(-1)
+	})
 	if err != nil {
-		xerr := xerrors.Errorf("Error migrate.NewWithDatabaseInstance : %+w", err)
+		xerr := xerrors.Errorf(": %+w", err)
 		logz.Errorf(ctx, " %+v", xerr)
 		return xerr
 	}
-
-	if migration != nil {
-		err := migration.Steps(-1)
-		if err != nil {
-			xerr := xerrors.Errorf(": %+w", err)
-			logz.Errorf(ctx, " %+v", xerr)
-			return xerr
-		}
-	}
 	return nil
 }
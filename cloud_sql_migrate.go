@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"github.com/glassonion1/logz"
+	"github.com/golang-migrate/migrate"
+	"github.com/golang-migrate/migrate/database"
+	golang_migrate_mysql "github.com/golang-migrate/migrate/database/mysql"
+	golang_migrate_postgres "github.com/golang-migrate/migrate/database/postgres"
+	"github.com/golang-migrate/migrate/source"
+	"golang.org/x/xerrors"
+	"hash/fnv"
+	"os"
+	"time"
+)
+
+const (
+	// Table in which golang-migrate records the version of every driver
+	migrationsTable = "schema_migrations"
+
+	// Polling interval of pg_try_advisory_lock
+	postgresLockInterval = 500 * time.Millisecond
+)
+
+var (
+	ErrMigrationLocked = xerrors.New("another instance holds the migration lock")
+)
+
+type (
+	// State of the schema against the migrations of DB_DRIVER
+	MigrationStatus struct {
+		// 0 when no migration has been applied
+		Version uint `json:"version"`
+		// The last migration failed half way, see ForceVersion
+		Dirty bool `json:"dirty"`
+		// Versions not applied yet in ascending order
+		Pending []uint `json:"pending"`
+	}
+)
+
+// Apply every pending migration
+func (c *cloudSQL) MigrateUp(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.withMigrate(ctx, func(m *migrate.Migrate) error {
+		return m.Up()
+	})
+	if err != nil && !xerrors.Is(err, migrate.ErrNoChange) {
+		xerr := xerrors.Errorf("MigrateUp : %+w", err)
+		logz.Errorf(ctx, " %+v", xerr)
+		return xerr
+	}
+	return nil
+}
+
+// MigrateUp under an advisory lock held by one instance at a time, so that instances
+// starting together do not run the same migrations. ErrMigrationLocked is returned when
+// the lock is not acquired within the timeout. SQLite is local to the process and has no lock.
+func (c *cloudSQL) MigrateUpLocked(ctx context.Context, timeout time.Duration) error {
+	err := c.withMigrationLock(ctx, timeout, func() error {
+		return c.MigrateUp(ctx)
+	})
+	if err != nil {
+		return xerrors.Errorf("MigrateUpLocked : %+w", err)
+	}
+	return nil
+}
+
+// Migrate up or down to the version, 0 rolls every migration back
+func (c *cloudSQL) MigrateTo(ctx context.Context, version uint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.withMigrate(ctx, func(m *migrate.Migrate) error {
+		if version == 0 {
+			return m.Down()
+		}
+		return m.Migrate(version)
+	})
+	if err != nil && !xerrors.Is(err, migrate.ErrNoChange) {
+		xerr := xerrors.Errorf("MigrateTo %d : %+w", version, err)
+		logz.Errorf(ctx, " %+v", xerr)
+		return xerr
+	}
+	return nil
+}
+
+func (c *cloudSQL) MigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := &MigrationStatus{Pending: []uint{}}
+	err := c.withMigrate(ctx, func(m *migrate.Migrate) error {
+		version, dirty, err := m.Version()
+		if err != nil && err != migrate.ErrNilVersion {
+			return err
+		}
+		status.Version, status.Dirty = version, dirty
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("MigrationStatus : %+w", err)
+	}
+
+	versions, err := c.migrationVersions()
+	if err != nil {
+		return nil, xerrors.Errorf("MigrationStatus : %+w", err)
+	}
+	for _, version := range versions {
+		if status.Version < version {
+			status.Pending = append(status.Pending, version)
+		}
+	}
+	return status, nil
+}
+
+// Version and dirty flag recorded by the migrations, 0 when none has been applied.
+// Read on the shared pool without c.mu, so that it answers while a migration runs.
+func (c *cloudSQL) SchemaVersion(ctx context.Context) (uint, bool, error) {
+	if c.db == nil {
+		return 0, false, xerrors.Errorf("SchemaVersion : database connection is not open")
+	}
+
+	db := c.DB(ctx)
+	if !db.Migrator().HasTable(migrationsTable) {
+		return 0, false, nil
+	}
+
+	row := struct {
+		Version int64
+		Dirty   bool
+	}{}
+	if err := db.Table(migrationsTable).Select("version, dirty").Limit(1).Scan(&row).Error; err != nil {
+		return 0, false, xerrors.Errorf("SchemaVersion : %+w", err)
+	}
+	return uint(row.Version), row.Dirty, nil
+}
+
+// Set the version without running any migration and clear the dirty flag.
+// The schema has to be fixed by hand to match the version first. -1 means no version.
+func (c *cloudSQL) ForceVersion(ctx context.Context, version int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.withMigrate(ctx, func(m *migrate.Migrate) error {
+		return m.Force(version)
+	})
+	if err != nil {
+		xerr := xerrors.Errorf("ForceVersion %d : %+w", version, err)
+		logz.Errorf(ctx, " %+v", xerr)
+		return xerr
+	}
+	return nil
+}
+
+// Run fn with a migrate instance on the migrations of DB_DRIVER.
+// The caller holds c.mu.
+func (c *cloudSQL) withMigrate(ctx context.Context, fn func(m *migrate.Migrate) error) error {
+	db, err := c.DB(ctx).DB()
+	if err != nil {
+		return xerrors.Errorf("Error db.DB() : %+w", err)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		return xerrors.Errorf("could not ping DB...  : %+w", err)
+	}
+
+	driver, err := c.migrationDriver(db)
+	if err != nil {
+		return xerrors.Errorf("Error migrationDriver : %+w", err)
+	}
+
+	src, err := c.migrationSource()
+	if err != nil {
+		driver.Close()
+		return xerrors.Errorf("Error migrationSource : %+w", err)
+	}
+
+	migration, err := migrate.NewWithInstance("fs", src, c.config.Name, driver)
+	if err != nil {
+		src.Close()
+		driver.Close()
+		return xerrors.Errorf("Error migrate.NewWithInstance : %+w", err)
+	}
+	// Releases the connection the driver holds
+	defer migration.Close()
+
+	return fn(migration)
+}
+
+// Run fn holding the advisory lock. The lock belongs to the session,
+// so it is taken and released on one dedicated connection.
+func (c *cloudSQL) withMigrationLock(ctx context.Context, timeout time.Duration, fn func() error) error {
+	if c.config.DBDriver == DB_DRIVER_SQLITE {
+		return fn()
+	}
+
+	db, err := c.DB(ctx).DB()
+	if err != nil {
+		return xerrors.Errorf("Error db.DB() : %+w", err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return xerrors.Errorf("Error db.Conn() : %+w", err)
+	}
+	defer conn.Close()
+
+	name := c.config.ImageName + "-migrations"
+	var release func() error
+	switch c.config.DBDriver {
+	case DB_DRIVER_POSTGRES:
+		release, err = postgresAdvisoryLock(ctx, conn, name, timeout)
+	default:
+		release, err = mysqlAdvisoryLock(ctx, conn, name, timeout)
+	}
+	if err != nil {
+		return xerrors.Errorf("withMigrationLock : %+w", err)
+	}
+	defer func() {
+		if err := release(); err != nil {
+			logz.Warningf(ctx, "Failed to release the migration lock. %+v", err)
+		}
+	}()
+
+	return fn()
+}
+
+// https://dev.mysql.com/doc/refman/5.7/en/locking-functions.html#function_get-lock
+func mysqlAdvisoryLock(ctx context.Context, conn *sql.Conn, name string, timeout time.Duration) (func() error, error) {
+	// 1 when acquired, 0 on timeout and NULL on error
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, int(timeout.Seconds())).Scan(&acquired); err != nil {
+		return nil, xerrors.Errorf("GET_LOCK : %+w", err)
+	}
+	if acquired.Int64 != 1 {
+		return nil, xerrors.Errorf("GET_LOCK %s : %+w", name, ErrMigrationLocked)
+	}
+
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), "DO RELEASE_LOCK(?)", name)
+		return err
+	}, nil
+}
+
+// pg_advisory_lock has no timeout, pg_try_advisory_lock is polled until it runs out
+// https://www.postgresql.org/docs/current/functions-admin.html#FUNCTIONS-ADVISORY-LOCKS
+func postgresAdvisoryLock(ctx context.Context, conn *sql.Conn, name string, timeout time.Duration) (func() error, error) {
+	hash := fnv.New64a()
+	hash.Write([]byte(name))
+	key := int64(hash.Sum64())
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+			return nil, xerrors.Errorf("pg_try_advisory_lock : %+w", err)
+		}
+		if acquired {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, xerrors.Errorf("pg_try_advisory_lock %s : %+w", name, ErrMigrationLocked)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, xerrors.Errorf("pg_try_advisory_lock : %+w", ctx.Err())
+		case <-time.After(postgresLockInterval):
+		}
+	}
+
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		return err
+	}, nil
+}
+
+// Versions of all migrations in ascending order
+func (c *cloudSQL) migrationVersions() ([]uint, error) {
+	src, err := c.migrationSource()
+	if err != nil {
+		return nil, xerrors.Errorf("Error migrationSource : %+w", err)
+	}
+	defer src.Close()
+
+	var versions []uint
+	version, err := src.First()
+	for err == nil {
+		versions = append(versions, version)
+		version, err = src.Next(version)
+	}
+	if !os.IsNotExist(err) {
+		return nil, xerrors.Errorf("Error source : %+w", err)
+	}
+	return versions, nil
+}
+
+// Migrations of DB_DRIVER, embedded in the binary unless MIGRATIONS_PATH is set
+func (c *cloudSQL) migrationSource() (source.Driver, error) {
+	if c.config.MigrationsPath != "" {
+		return newFSMigrateSource(os.DirFS(c.config.MigrationsPath), c.config.DBDriver)
+	}
+	return newFSMigrateSource(embeddedMigrations, "migrations/"+c.config.DBDriver)
+}
+
+// golang-migrate driver of DB_DRIVER. Migrations of each driver are in migrations/<DB_DRIVER>.
+// The MySQL and PostgreSQL drivers hold a connection until they are closed, which closes
+// the whole pool, so they get a pool of their own.
+func (c *cloudSQL) migrationDriver(db *sql.DB) (database.Driver, error) {
+	switch c.config.DBDriver {
+	case DB_DRIVER_MYSQL:
+		return withOwnPool("mysql", c.dsn, func(db *sql.DB) (database.Driver, error) {
+			return golang_migrate_mysql.WithInstance(db, &golang_migrate_mysql.Config{})
+		})
+	case DB_DRIVER_POSTGRES:
+		// Registered by pgx, which the GORM driver uses
+		return withOwnPool("pgx", c.dsn, func(db *sql.DB) (database.Driver, error) {
+			return golang_migrate_postgres.WithInstance(db, &golang_migrate_postgres.Config{})
+		})
+	case DB_DRIVER_SQLITE:
+		return newSQLiteMigrateDriver(db)
+	}
+	return nil, xerrors.Errorf("unknown DB_DRIVER : %s", c.config.DBDriver)
+}
+
+// Driver made by fn on a new pool, which is closed with the driver
+func withOwnPool(driverName string, dsn string, fn func(db *sql.DB) (database.Driver, error)) (database.Driver, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := fn(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return driver, nil
+}
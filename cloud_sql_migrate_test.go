@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+	"testing"
+	"time"
+)
+
+func TestCloudSQLMigrate(t *testing.T) {
+	t.Helper()
+	skipWithoutDatabase(t)
+	ctx := context.Background()
+	dao := newTestCloudSQL(t, ctx)
+
+	status, err := dao.MigrationStatus(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &MigrationStatus{Version: 0, Dirty: false, Pending: []uint{1, 2, 3, 4, 5}}, status)
+
+	t.Run("Migrate to a version and up", func(t *testing.T) {
+		assert.Nil(t, dao.MigrateTo(ctx, 3))
+		status, err := dao.MigrationStatus(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(3), status.Version)
+		assert.Equal(t, []uint{4, 5}, status.Pending)
+		version, dirty, err := dao.SchemaVersion(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(3), version)
+		assert.False(t, dirty)
+
+		assert.Nil(t, dao.MigrateUp(ctx))
+		status, err = dao.MigrationStatus(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(5), status.Version)
+		assert.Equal(t, []uint{}, status.Pending)
+
+		// Nothing left to apply
+		assert.Nil(t, dao.MigrateUp(ctx))
+
+		// Down as well
+		assert.Nil(t, dao.MigrateTo(ctx, 1))
+		status, err = dao.MigrationStatus(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(1), status.Version)
+	})
+
+	t.Run("Force a dirty version", func(t *testing.T) {
+		assert.Nil(t, dao.DB(ctx).Exec("UPDATE schema_migrations SET dirty = true").Error)
+		status, err := dao.MigrationStatus(ctx)
+		assert.Nil(t, err)
+		assert.True(t, status.Dirty)
+		_, dirty, err := dao.SchemaVersion(ctx)
+		assert.Nil(t, err)
+		assert.True(t, dirty)
+
+		// Refused until the version is forced
+		assert.NotNil(t, dao.MigrateUp(ctx))
+
+		assert.Nil(t, dao.ForceVersion(ctx, 1))
+		status, err = dao.MigrationStatus(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(1), status.Version)
+		assert.False(t, status.Dirty)
+	})
+
+	t.Run("Migrate up under the lock", func(t *testing.T) {
+		assert.Nil(t, dao.MigrateUpLocked(ctx, time.Second))
+		status, err := dao.MigrationStatus(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(5), status.Version)
+		assert.Nil(t, dao.MigrateTo(ctx, 0))
+		status, err = dao.MigrationStatus(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(0), status.Version)
+
+		assert.Nil(t, dao.MigrateTo(ctx, 1))
+	})
+
+	t.Run("Lock held by another instance", func(t *testing.T) {
+		skipUnlessMySQL(t)
+
+		db, err := dao.DB(ctx).DB()
+		assert.Nil(t, err)
+		conn, err := db.Conn(ctx)
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		release, err := mysqlAdvisoryLock(ctx, conn, GetApplicationConfig(ctx).ImageName+"-migrations", time.Second)
+		assert.Nil(t, err)
+		defer release()
+
+		err = dao.MigrateUpLocked(ctx, time.Second)
+		assert.True(t, xerrors.Is(err, ErrMigrationLocked))
+	})
+
+	assert.Nil(t, dao.RollbackLastMigrations(ctx))
+	status, err = dao.MigrationStatus(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(0), status.Version)
+}
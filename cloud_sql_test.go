@@ -2,29 +2,33 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
 	"testing"
 )
 
 // Test MySQL Smoke
 func TestCloudSQL(t *testing.T) {
 	t.Helper()
+	skipWithoutDatabase(t)
 	t.Parallel()
 	ctx := context.Background()
 
 	t.Run("GORM Connection Open Test", func(t *testing.T) {
 		t.Parallel()
 
-		dao := NewCloudSQL(ctx)
-		db := dao.DB()
+		dao := newTestCloudSQL(t, ctx)
+		db := dao.DB(ctx)
 		assert.NotNil(t, db)
 	})
 
 	t.Run("Migration create and delete", func(t *testing.T) {
 		t.Parallel()
 
-		dao := NewCloudSQL(ctx)
-		db := dao.DB()
+		dao := newTestCloudSQL(t, ctx)
+		db := dao.DB(ctx)
 		assert.NotNil(t, db)
 
 		err := dao.StartMigrations(ctx)
@@ -34,6 +38,15 @@ func TestCloudSQL(t *testing.T) {
 		assert.Nil(t, err)
 
 	})
+
+	t.Run("Close", func(t *testing.T) {
+		t.Parallel()
+
+		dao := newTestCloudSQL(t, ctx)
+		assert.Nil(t, dao.Ping(ctx))
+		assert.Nil(t, dao.Close())
+		assert.NotNil(t, dao.Ping(ctx))
+	})
 	// Remove comments to generate model in the database automatically.
 	//t.Run("Generate Models From Tables", func(t *testing.T) {
 	//	seedDataPath, _ := os.Getwd()
@@ -43,3 +56,117 @@ func TestCloudSQL(t *testing.T) {
 	//		Run()
 	//})
 }
+
+func TestCloudSQLDSN(t *testing.T) {
+	t.Parallel()
+
+	c := &cloudSQL{}
+
+	t.Run("PostgreSQL over TCP", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t,
+			"host='127.0.0.1' port=5432 user='postgres' password='pa ss\\'word' dbname='test' sslmode=disable TimeZone=UTC",
+			c.GenerateDSNPostgresLocal("test", "postgres", "pa ss'word", "127.0.0.1", 5432))
+	})
+
+	t.Run("PostgreSQL over the Cloud SQL socket", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t,
+			"host='/cloudsql/project:region:instance' user='postgres' password='password' dbname='test' sslmode=disable TimeZone=UTC",
+			c.GenerateDSNPostgresForCloudDB("test", "postgres", "password", "project:region:instance"))
+	})
+}
+
+func TestCloudSQLWithTx(t *testing.T) {
+	t.Helper()
+	skipWithoutDatabase(t)
+	ctx := context.Background()
+
+	t.Run("Commit, rollback and savepoint", eachTestWrapper(func(t *testing.T) {
+		dao := newTestCloudSQL(t, ctx)
+		count := func() int64 {
+			var n int64
+			assert.Nil(t, dao.DB(ctx).Model(&Todo{}).Count(&n).Error)
+			return n
+		}
+
+		// Committed
+		err := dao.WithTx(ctx, func(tx CloudSQL) error {
+			return tx.DB(ctx).Create(&Todo{Task: "committed"}).Error
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), count())
+
+		// Rolled back
+		err = dao.WithTx(ctx, func(tx CloudSQL) error {
+			if err := tx.DB(ctx).Create(&Todo{Task: "rolled back"}).Error; err != nil {
+				return err
+			}
+			return ErrInvalidParameter
+		})
+		assert.True(t, xerrors.Is(err, ErrInvalidParameter))
+		assert.Equal(t, int64(1), count())
+
+		// Only the savepoint is rolled back
+		err = dao.WithTx(ctx, func(tx CloudSQL) error {
+			if err := tx.DB(ctx).Create(&Todo{Task: "outer"}).Error; err != nil {
+				return err
+			}
+			_ = tx.WithTx(ctx, func(sp CloudSQL) error {
+				if err := sp.DB(ctx).Create(&Todo{Task: "inner"}).Error; err != nil {
+					return err
+				}
+				return ErrInvalidParameter
+			})
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), count())
+	}))
+
+	t.Run("Retry on deadlock", eachTestWrapper(func(t *testing.T) {
+		skipUnlessMySQL(t)
+		dao := newTestCloudSQL(t, ctx)
+
+		attempts := 0
+		err := dao.WithTx(ctx, func(tx CloudSQL) error {
+			attempts++
+			if err := tx.DB(ctx).Create(&Todo{Task: "retried"}).Error; err != nil {
+				return err
+			}
+			if attempts == 1 {
+				return &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+			}
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, attempts)
+
+		// The first attempt has been rolled back
+		var n int64
+		assert.Nil(t, dao.DB(ctx).Model(&Todo{}).Count(&n).Error)
+		assert.Equal(t, int64(1), n)
+
+		// Gives up
+		attempts = 0
+		err = dao.WithTx(ctx, func(tx CloudSQL) error {
+			attempts++
+			return &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}
+		}, WithMaxRetries(2))
+		assert.NotNil(t, err)
+		assert.Equal(t, 3, attempts)
+	}))
+
+	t.Run("Read only", eachTestWrapper(func(t *testing.T) {
+		// The SQLite driver ignores read only
+		skipUnlessMySQL(t)
+		dao := newTestCloudSQL(t, ctx)
+
+		err := dao.WithTx(ctx, func(tx CloudSQL) error {
+			return tx.DB(ctx).Create(&Todo{Task: "read only"}).Error
+		}, WithReadOnly(), WithIsolationLevel(sql.LevelReadCommitted))
+		assert.NotNil(t, err)
+	}))
+}
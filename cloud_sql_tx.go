@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"github.com/glassonion1/logz"
+	sqlite "github.com/glebarez/go-sqlite"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"golang.org/x/xerrors"
+	"gorm.io/gorm"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	// https://dev.mysql.com/doc/mysql-errors/5.7/en/server-error-reference.html
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+
+	// https://www.postgresql.org/docs/current/errcodes-appendix.html
+	postgresErrSerializationFailure = "40001"
+	postgresErrDeadlockDetected     = "40P01"
+	postgresErrLockNotAvailable     = "55P03"
+
+	// https://www.sqlite.org/rescode.html
+	sqliteErrBusy   = 5
+	sqliteErrLocked = 6
+
+	// Doubled on every retry
+	txRetryBaseDelay = 20 * time.Millisecond
+)
+
+var (
+	ErrCloseInTx = xerrors.New("the shared connections can not be closed in a transaction")
+
+	// Values of DB_TX_ISOLATION, empty for the server default
+	isolationLevels = map[string]sql.IsolationLevel{
+		"":                 sql.LevelDefault,
+		"READ UNCOMMITTED": sql.LevelReadUncommitted,
+		"READ COMMITTED":   sql.LevelReadCommitted,
+		"REPEATABLE READ":  sql.LevelRepeatableRead,
+		"SERIALIZABLE":     sql.LevelSerializable,
+	}
+)
+
+type (
+	TxOption func(*txOptions)
+
+	txOptions struct {
+		isolation  sql.IsolationLevel
+		readOnly   bool
+		maxRetries int
+	}
+
+	// CloudSQL bound to a transaction. Every query of DB runs in the transaction,
+	// WithTx makes a savepoint.
+	cloudSQLTx struct {
+		*cloudSQL
+		tx *gorm.DB
+	}
+)
+
+// Isolation level of the transaction, DB_TX_ISOLATION when not given
+func WithIsolationLevel(level sql.IsolationLevel) TxOption {
+	return func(o *txOptions) {
+		o.isolation = level
+	}
+}
+
+func WithReadOnly() TxOption {
+	return func(o *txOptions) {
+		o.readOnly = true
+	}
+}
+
+// Times to retry on deadlock and lock wait timeout, DB_TX_MAX_RETRIES when not given
+func WithMaxRetries(n int) TxOption {
+	return func(o *txOptions) {
+		o.maxRetries = n
+	}
+}
+
+// Unit of work. fn runs in a transaction which is committed when it returns nil
+// and rolled back otherwise. The whole fn is run again on deadlock and lock wait
+// timeout, so it must not have side effects outside of the transaction.
+// https://gorm.io/docs/transactions.html
+func (c *cloudSQL) WithTx(ctx context.Context, fn func(tx CloudSQL) error, opts ...TxOption) error {
+	o := &txOptions{isolation: c.isolation, maxRetries: c.config.TxMaxRetries}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	err := retryTx(ctx, o.maxRetries, func() error {
+		return c.DB(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(&cloudSQLTx{cloudSQL: c, tx: tx})
+		}, &sql.TxOptions{Isolation: o.isolation, ReadOnly: o.readOnly})
+	})
+	if err != nil {
+		return xerrors.Errorf("WithTx : %+w", err)
+	}
+	return nil
+}
+
+func (c *cloudSQLTx) DB(ctx context.Context) *gorm.DB {
+	return c.tx.WithContext(ctx)
+}
+
+// The pool is shared with every other request, it is closed through the CloudSQL
+// the transaction was begun on
+func (c *cloudSQLTx) Close() error {
+	return xerrors.Errorf("Close : %+w", ErrCloseInTx)
+}
+
+// Nested unit of work in a savepoint. Only the savepoint is rolled back when fn fails.
+// Options are ignored since the transaction has already begun, and retrying is left
+// to the outermost WithTx because deadlocks roll back the whole transaction.
+func (c *cloudSQLTx) WithTx(ctx context.Context, fn func(tx CloudSQL) error, opts ...TxOption) error {
+	return c.DB(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&cloudSQLTx{cloudSQL: c.cloudSQL, tx: tx})
+	})
+}
+
+// Run fn, and run it again up to maxRetries times while it fails with a retryable error
+func retryTx(ctx context.Context, maxRetries int, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || maxRetries <= attempt || !isRetryableTxError(err) {
+			return err
+		}
+
+		delay := txRetryDelay(attempt)
+		logz.Warningf(ctx, "retrying transaction in %s : %+v", delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return xerrors.Errorf("retryTx : %v : %+w", err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// Exponential backoff with jitter
+func txRetryDelay(attempt int) time.Duration {
+	delay := txRetryBaseDelay << uint(attempt)
+	return delay + time.Duration(rand.Int63n(int64(delay)))
+}
+
+// Deadlock and lock wait timeout, after which the transaction can just be run again.
+// PostgreSQL also fails REPEATABLE READ transactions on concurrent updates,
+// SQLite reports both as busy or locked.
+func isRetryableTxError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if xerrors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrDeadlock || mysqlErr.Number == mysqlErrLockWaitTimeout
+	}
+
+	var pgErr *pgconn.PgError
+	if xerrors.As(err, &pgErr) {
+		return pgErr.Code == postgresErrSerializationFailure ||
+			pgErr.Code == postgresErrDeadlockDetected ||
+			pgErr.Code == postgresErrLockNotAvailable
+	}
+
+	var sqliteErr *sqlite.Error
+	if xerrors.As(err, &sqliteErr) {
+		// The lower byte is the primary result code
+		code := sqliteErr.Code() & 0xff
+		return code == sqliteErrBusy || code == sqliteErrLocked
+	}
+	return false
+}
+
+func parseIsolationLevel(value string) (sql.IsolationLevel, error) {
+	level, ok := isolationLevels[strings.ToUpper(strings.TrimSpace(value))]
+	if !ok {
+		return sql.LevelDefault, xerrors.Errorf("unknown isolation level : %s", value)
+	}
+	return level, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+	"testing"
+)
+
+func TestTx(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("Close in a transaction", func(t *testing.T) {
+		t.Parallel()
+		skipWithoutDatabase(t)
+
+		cloudSQL := newTestCloudSQL(t, ctx)
+		err := cloudSQL.WithTx(ctx, func(tx CloudSQL) error {
+			return tx.Close()
+		})
+		assert.True(t, xerrors.Is(err, ErrCloseInTx))
+
+		server, serverErr := fakestorage.NewServerWithOptions(fakestorage.Options{NoListener: true})
+		assert.Nil(t, serverErr)
+		repository, err := NewRepository(ctx, server.Client(), WithCloudSQL(cloudSQL))
+		assert.Nil(t, err)
+		err = repository.WithTx(ctx, func(tx Repository) error {
+			return tx.Close()
+		})
+		assert.True(t, xerrors.Is(err, ErrCloseInTx))
+
+		// The pool is still open
+		assert.Nil(t, cloudSQL.Ping(ctx))
+	})
+
+	t.Run("isRetryableTxError", func(t *testing.T) {
+		t.Parallel()
+
+		assert.True(t, isRetryableTxError(xerrors.Errorf("Update : %+w", &mysql.MySQLError{Number: 1213})))
+		assert.True(t, isRetryableTxError(&mysql.MySQLError{Number: 1205}))
+		// Duplicate entry
+		assert.False(t, isRetryableTxError(&mysql.MySQLError{Number: 1062}))
+		assert.False(t, isRetryableTxError(ErrVersionConflict))
+
+		assert.True(t, isRetryableTxError(xerrors.Errorf("Update : %+w", &pgconn.PgError{Code: "40001"})))
+		assert.True(t, isRetryableTxError(&pgconn.PgError{Code: "40P01"}))
+		// Unique violation
+		assert.False(t, isRetryableTxError(&pgconn.PgError{Code: "23505"}))
+	})
+
+	t.Run("retryTx", func(t *testing.T) {
+		t.Parallel()
+
+		attempts := 0
+		err := retryTx(ctx, 3, func() error {
+			attempts++
+			if attempts < 3 {
+				return &mysql.MySQLError{Number: 1213}
+			}
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, attempts)
+
+		// Not retryable
+		attempts = 0
+		err = retryTx(ctx, 3, func() error {
+			attempts++
+			return ErrVersionConflict
+		})
+		assert.True(t, xerrors.Is(err, ErrVersionConflict))
+		assert.Equal(t, 1, attempts)
+
+		// Canceled while waiting
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		attempts = 0
+		err = retryTx(canceled, 3, func() error {
+			attempts++
+			return &mysql.MySQLError{Number: 1205}
+		})
+		assert.True(t, xerrors.Is(err, context.Canceled))
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("txRetryDelay", func(t *testing.T) {
+		t.Parallel()
+
+		for attempt := 0; attempt < 4; attempt++ {
+			delay := txRetryDelay(attempt)
+			assert.GreaterOrEqual(t, int64(delay), int64(txRetryBaseDelay<<uint(attempt)))
+			assert.Less(t, int64(delay), int64(txRetryBaseDelay<<uint(attempt+1)))
+		}
+	})
+
+	t.Run("parseIsolationLevel", func(t *testing.T) {
+		t.Parallel()
+
+		level, err := parseIsolationLevel("read committed")
+		assert.Nil(t, err)
+		assert.Equal(t, sql.LevelReadCommitted, level)
+
+		level, err = parseIsolationLevel("")
+		assert.Nil(t, err)
+		assert.Equal(t, sql.LevelDefault, level)
+
+		_, err = parseIsolationLevel("SNAPSHOT")
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"golang.org/x/xerrors"
+	"strings"
+	"time"
+)
+
+const (
+	QueryCursor = "cursor"
+)
+
+var (
+	ErrInvalidCursor = NewDomainError(KindInvalid, "invalid_cursor", "invalid cursor")
+)
+
+type (
+	// Keyset position of the last row of a page.
+	// Rows are ordered by updated_at DESC, id DESC.
+	Cursor struct {
+		UpdatedAt time.Time `json:"u"`
+		ID        int64     `json:"i"`
+		// Filter and order of the listing the cursor was issued for, see CursorQuery
+		Query string `json:"q"`
+	}
+)
+
+// Encode the cursor into an opaque token signed with HMAC-SHA256
+// so that clients can not forge a position.
+func EncodeCursor(secret string, cursor *Cursor) (string, error) {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", xerrors.Errorf("EncodeCursor : %+w", err)
+	}
+
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + signCursor(secret, body), nil
+}
+
+// Decode and verify the token generated by EncodeCursor.
+// query must be the Query of the cursor, so that the position of a listing
+// is not carried over to another filter or order.
+func DecodeCursor(secret string, token string, query string) (*Cursor, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, xerrors.Errorf("DecodeCursor : malformed token : %+w", ErrInvalidCursor)
+	}
+
+	if !hmac.Equal([]byte(parts[1]), []byte(signCursor(secret, parts[0]))) {
+		return nil, xerrors.Errorf("DecodeCursor : signature mismatch : %+w", ErrInvalidCursor)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, xerrors.Errorf("DecodeCursor : %+w", ErrInvalidCursor.Wrap(err))
+	}
+
+	cursor := &Cursor{}
+	if err := json.Unmarshal(payload, cursor); err != nil {
+		return nil, xerrors.Errorf("DecodeCursor : %+w", ErrInvalidCursor.Wrap(err))
+	}
+	if cursor.Query != query {
+		return nil, xerrors.Errorf("DecodeCursor : %+w", ErrInvalidCursor.Withf("the cursor was issued for another query"))
+	}
+
+	return cursor, nil
+}
+
+// Digest of the filter and order of a listing, bound into the cursors of its pages
+func CursorQuery(filter *TodoFilter, orders []SortOrder) (string, error) {
+	payload, err := json.Marshal(struct {
+		Filter *TodoFilter
+		Orders []SortOrder
+	}{filter, orders})
+	if err != nil {
+		return "", xerrors.Errorf("CursorQuery : %+w", err)
+	}
+
+	digest := sha256.Sum256(payload)
+	return base64.RawURLEncoding.EncodeToString(digest[:]), nil
+}
+
+func signCursor(secret string, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
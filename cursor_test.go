@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	t.Parallel()
+	const secret = "test-secret"
+
+	t.Run("Encode and Decode", func(t *testing.T) {
+		t.Parallel()
+
+		cursor := &Cursor{UpdatedAt: time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC), ID: 42, Query: "query"}
+		token, err := EncodeCursor(secret, cursor)
+		assert.Nil(t, err)
+		assert.NotEmpty(t, token)
+
+		decoded, err := DecodeCursor(secret, token, "query")
+		assert.Nil(t, err)
+		assert.Equal(t, cursor.ID, decoded.ID)
+		assert.True(t, cursor.UpdatedAt.Equal(decoded.UpdatedAt))
+	})
+
+	t.Run("Reject tampered token", func(t *testing.T) {
+		t.Parallel()
+
+		token, err := EncodeCursor(secret, &Cursor{UpdatedAt: time.Now().UTC(), ID: 1, Query: "query"})
+		assert.Nil(t, err)
+
+		// Signed with another secret
+		_, err = DecodeCursor("other-secret", token, "query")
+		assert.True(t, xerrors.Is(err, ErrInvalidCursor))
+
+		// Issued for another query
+		_, err = DecodeCursor(secret, token, "other-query")
+		assert.True(t, xerrors.Is(err, ErrInvalidCursor))
+
+		// Malformed
+		_, err = DecodeCursor(secret, "not-a-cursor", "query")
+		assert.True(t, xerrors.Is(err, ErrInvalidCursor))
+	})
+
+	t.Run("Query of the filter and order", func(t *testing.T) {
+		t.Parallel()
+
+		done := true
+		all, err := CursorQuery(nil, defaultTodoSort)
+		assert.Nil(t, err)
+		same, err := CursorQuery(nil, defaultTodoSort)
+		assert.Nil(t, err)
+		filtered, err := CursorQuery(&TodoFilter{Status: &done}, defaultTodoSort)
+		assert.Nil(t, err)
+		ascending, err := CursorQuery(nil, []SortOrder{{Column: "updated_at"}, {Column: "id"}})
+		assert.Nil(t, err)
+
+		assert.Equal(t, all, same)
+		assert.NotEqual(t, all, filtered)
+		assert.NotEqual(t, all, ascending)
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// RFC 9745 The Deprecation HTTP Response Header Field
+	// https://datatracker.ietf.org/doc/html/rfc9745
+	HeaderDeprecation = "Deprecation"
+	// RFC 8594 The Sunset HTTP Header Field
+	// https://datatracker.ietf.org/doc/html/rfc8594
+	HeaderSunset = "Sunset"
+)
+
+type (
+	DeprecationConfig struct {
+		// Since when the routes are deprecated
+		Deprecation time.Time
+		// When the routes are expected to go away, no Sunset header when zero
+		Sunset time.Time
+		// Path of the replacement linked as the successor-version, none when empty
+		Successor string
+	}
+)
+
+// Tell clients that the routes are deprecated and what replaces them
+func DeprecationMiddleware(config DeprecationConfig) echo.MiddlewareFunc {
+	deprecation := "@" + strconv.FormatInt(config.Deprecation.Unix(), 10)
+	sunset := config.Sunset.UTC().Format(http.TimeFormat)
+	successor := fmt.Sprintf("<%s>; rel=\"successor-version\"", config.Successor)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Response().Header()
+			header.Set(HeaderDeprecation, deprecation)
+			if !config.Sunset.IsZero() {
+				header.Set(HeaderSunset, sunset)
+			}
+			if config.Successor != "" {
+				header.Add(HeaderLink, successor)
+			}
+			return next(c)
+		}
+	}
+}
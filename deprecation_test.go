@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeprecationMiddleware(t *testing.T) {
+	t.Parallel()
+
+	serve := func(config DeprecationConfig) http.Header {
+		e := echo.New()
+		e.GET("/old", func(c echo.Context) error {
+			c.Response().Header().Add(HeaderLink, "</old?page=2>; rel=\"next\"")
+			return c.NoContent(http.StatusNoContent)
+		}, DeprecationMiddleware(config))
+
+		req := httptest.NewRequest(http.MethodGet, "/old", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Header()
+	}
+
+	t.Run("Headers", func(t *testing.T) {
+		t.Parallel()
+
+		header := serve(DeprecationConfig{
+			Deprecation: time.Date(2023, time.June, 30, 23, 59, 59, 0, time.UTC),
+			Sunset:      time.Date(2024, time.June, 30, 23, 59, 59, 0, time.FixedZone("JST", 9*60*60)),
+			Successor:   "/new",
+		})
+		assert.Equal(t, "@1688169599", header.Get(HeaderDeprecation))
+		assert.Equal(t, "Sun, 30 Jun 2024 14:59:59 GMT", header.Get(HeaderSunset))
+		// The links of the handler are kept
+		assert.Equal(t, []string{"</new>; rel=\"successor-version\"", "</old?page=2>; rel=\"next\""}, header.Values(HeaderLink))
+	})
+
+	t.Run("Without sunset and successor", func(t *testing.T) {
+		t.Parallel()
+
+		header := serve(DeprecationConfig{Deprecation: time.Unix(0, 0)})
+		assert.Equal(t, "@0", header.Get(HeaderDeprecation))
+		assert.Empty(t, header.Get(HeaderSunset))
+		assert.Equal(t, []string{"</old?page=2>; rel=\"next\""}, header.Values(HeaderLink))
+	})
+}
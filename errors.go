@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"golang.org/x/xerrors"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+const (
+	// 400 Bad Request
+	KindInvalid ErrorKind = iota + 1
+	// 404 Not Found
+	KindNotFound
+	// 409 Conflict
+	KindConflict
+	// 412 Precondition Failed
+	KindPrecondition
+	// 415 Unsupported Media Type
+	KindUnsupported
+	// 422 Unprocessable Entity
+	KindValidation
+	// 424 Failed Dependency
+	KindFailedDependency
+	// 503 Service Unavailable
+	KindUnavailable
+)
+
+var (
+	ErrInvalidParameter   = NewDomainError(KindInvalid, "invalid_parameter", "invalid parameter")
+	ErrInvalidBody        = NewDomainError(KindInvalid, "invalid_body", "request body can not be parsed")
+	ErrTodoNotFound       = NewDomainError(KindNotFound, "todo_not_found", "todo not found")
+	ErrPreconditionFailed = NewDomainError(KindPrecondition, "precondition_failed", "precondition failed")
+	ErrValidation         = NewDomainError(KindValidation, "validation_failed", "request has invalid fields")
+
+	kindStatus = map[ErrorKind]int{
+		KindInvalid:          http.StatusBadRequest,
+		KindNotFound:         http.StatusNotFound,
+		KindConflict:         http.StatusConflict,
+		KindPrecondition:     http.StatusPreconditionFailed,
+		KindUnsupported:      http.StatusUnsupportedMediaType,
+		KindValidation:       http.StatusUnprocessableEntity,
+		KindFailedDependency: http.StatusFailedDependency,
+		KindUnavailable:      http.StatusServiceUnavailable,
+	}
+)
+
+type (
+	ErrorKind int
+
+	// Error of the domain, which the HTTP error handler turns into a problem.
+	// Code and Detail are shown to clients, the wrapped Err is only logged.
+	DomainError struct {
+		Kind   ErrorKind
+		Code   string
+		Detail string
+		// Fields which the client has to fix
+		Fields []*FieldError
+		Err    error
+	}
+
+	FieldError struct {
+		// JSON path of the field such as items[0].task
+		Field string `json:"field"`
+		// Rule which the value breaks such as required
+		Rule    string `json:"rule"`
+		Message string `json:"message"`
+	}
+)
+
+func NewDomainError(kind ErrorKind, code string, detail string) *DomainError {
+	return &DomainError{Kind: kind, Code: code, Detail: detail}
+}
+
+func (e *DomainError) Error() string {
+	if e.Err != nil {
+		return e.Detail + " : " + e.Err.Error()
+	}
+	return e.Detail
+}
+
+func (e *DomainError) Unwrap() error {
+	return e.Err
+}
+
+// Errors with the same code are the same error, whatever the detail and the cause are
+func (e *DomainError) Is(target error) bool {
+	t, ok := target.(*DomainError)
+	return ok && t.Code == e.Code
+}
+
+// Copy of the error wrapping the cause
+func (e *DomainError) Wrap(err error) *DomainError {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+// Copy of the error with the detail for clients
+func (e *DomainError) Withf(format string, a ...interface{}) *DomainError {
+	c := *e
+	c.Detail = fmt.Sprintf(format, a...)
+	return &c
+}
+
+// Copy of the error with the invalid fields
+func (e *DomainError) WithFields(fields []*FieldError) *DomainError {
+	c := *e
+	c.Fields = fields
+	return &c
+}
+
+// HTTP status code of the kind
+func (k ErrorKind) Status() int {
+	if status, ok := kindStatus[k]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Turn gorm.ErrRecordNotFound into ErrTodoNotFound, the other errors are returned as they are
+func todoNotFound(err error) error {
+	if xerrors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTodoNotFound.Wrap(err)
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+	"gorm.io/gorm"
+	"net/http"
+	"testing"
+)
+
+func TestDomainError(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Is by code", func(t *testing.T) {
+		t.Parallel()
+
+		err := xerrors.Errorf("Get : %+w", ErrTodoNotFound.Withf("todo %d is not found", 1))
+		assert.True(t, xerrors.Is(err, ErrTodoNotFound))
+		assert.False(t, xerrors.Is(err, ErrVersionConflict))
+
+		var domainErr *DomainError
+		assert.True(t, xerrors.As(err, &domainErr))
+		assert.Equal(t, "todo 1 is not found", domainErr.Detail)
+
+		// The sentinel is left as it is
+		assert.Equal(t, "todo not found", ErrTodoNotFound.Detail)
+	})
+
+	t.Run("Wrap keeps the cause", func(t *testing.T) {
+		t.Parallel()
+
+		err := todoNotFound(xerrors.Errorf("First : %+w", gorm.ErrRecordNotFound))
+		assert.True(t, xerrors.Is(err, ErrTodoNotFound))
+		assert.True(t, xerrors.Is(err, gorm.ErrRecordNotFound))
+
+		other := xerrors.New("connection refused")
+		assert.Equal(t, other, todoNotFound(other))
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, http.StatusBadRequest, ErrInvalidQuery.Kind.Status())
+		assert.Equal(t, http.StatusNotFound, ErrTodoNotFound.Kind.Status())
+		assert.Equal(t, http.StatusConflict, ErrVersionConflict.Kind.Status())
+		assert.Equal(t, http.StatusPreconditionFailed, ErrPreconditionFailed.Kind.Status())
+		assert.Equal(t, http.StatusUnsupportedMediaType, ErrUnsupportedPatch.Kind.Status())
+		assert.Equal(t, http.StatusUnprocessableEntity, ErrInvalidPatch.Kind.Status())
+		assert.Equal(t, http.StatusFailedDependency, ErrBatchRolledBack.Kind.Status())
+		assert.Equal(t, http.StatusServiceUnavailable, ErrRequestTimeout.Kind.Status())
+		assert.Equal(t, http.StatusInternalServerError, ErrorKind(0).Status())
+	})
+}
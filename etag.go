@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	HeaderETag        = "ETag"
+	HeaderIfMatch     = "If-Match"
+	HeaderIfNoneMatch = "If-None-Match"
+)
+
+// Strong entity tag of the todo, which changes every time the todo is updated
+func TodoETag(todo *Todo) string {
+	return fmt.Sprintf("\"%d-%d\"", todo.ID, todo.Version)
+}
+
+// Evaluate If-Match header value against the entity tag with the strong comparison.
+// "*" matches any entity tag, weak tags never match.
+// https://datatracker.ietf.org/doc/html/rfc7232#section-3.1
+func MatchETag(header string, etag string) bool {
+	return matchETag(header, func(candidate string) bool {
+		return !strings.HasPrefix(candidate, "W/") && !strings.HasPrefix(etag, "W/") && candidate == etag
+	})
+}
+
+// Evaluate If-None-Match header value against the entity tag with the weak comparison.
+// "*" matches any entity tag. Weak tags are compared by their opaque part.
+// https://datatracker.ietf.org/doc/html/rfc7232#section-3.2
+func MatchETagWeak(header string, etag string) bool {
+	return matchETag(header, func(candidate string) bool {
+		return strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/")
+	})
+}
+
+func matchETag(header string, match func(candidate string) bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || match(candidate) {
+			return true
+		}
+	}
+	return false
+}
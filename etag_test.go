@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestETag(t *testing.T) {
+	t.Parallel()
+
+	t.Run("TodoETag", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, "\"3-2\"", TodoETag(&Todo{ID: 3, Version: 2}))
+	})
+
+	t.Run("MatchETag", func(t *testing.T) {
+		t.Parallel()
+
+		etag := TodoETag(&Todo{ID: 3, Version: 2})
+		assert.True(t, MatchETag("\"3-2\"", etag))
+		assert.True(t, MatchETag("*", etag))
+		assert.True(t, MatchETag("\"3-1\", \"3-2\"", etag))
+		assert.False(t, MatchETag("\"3-1\"", etag))
+		// Strong comparison for If-Match
+		assert.False(t, MatchETag("W/\"3-2\"", etag))
+		assert.False(t, MatchETag("\"3-2\"", "W/"+etag))
+	})
+
+	t.Run("MatchETagWeak", func(t *testing.T) {
+		t.Parallel()
+
+		etag := TodoETag(&Todo{ID: 3, Version: 2})
+		assert.True(t, MatchETagWeak("\"3-2\"", etag))
+		assert.True(t, MatchETagWeak("*", etag))
+		assert.True(t, MatchETagWeak("\"3-1\", W/\"3-2\"", etag))
+		assert.False(t, MatchETagWeak("W/\"3-1\"", etag))
+	})
+}
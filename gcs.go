@@ -5,6 +5,7 @@ import (
 	"context"
 	"github.com/glassonion1/logz"
 	"golang.org/x/xerrors"
+	"google.golang.org/api/iterator"
 	"io/ioutil"
 )
 
@@ -14,6 +15,8 @@ type (
 		Write(ctx context.Context, bucketName string, objectName string, writeStr []byte, contentType string) (*storage.Writer, error)
 		Read(ctx context.Context, bucketName string, objectName string) ([]byte, error)
 		IsExist(ctx context.Context, bucketName string, objectName string) bool
+		PingBucket(ctx context.Context, bucketName string) error
+		Close() error
 	}
 
 	gcs struct {
@@ -31,7 +34,8 @@ func NewGCS(ctx context.Context, client *storage.Client) GCS {
 	g := &gcs{}
 
 	if client == nil {
-		// Production should be passed client is null, then create the new client
+		// Production should be passed client is null, then create the new client.
+		// STORAGE_EMULATOR_HOST points the client at an emulator without credentials.
 		newClient, err := storage.NewClient(ctx)
 		if err != nil {
 			logz.Criticalf(ctx, "%+v\n", xerrors.Errorf(": %+w", err))
@@ -95,3 +99,25 @@ func (g *gcs) IsExist(ctx context.Context, bucketName string, objectName string)
 
 	return true
 }
+
+// Check the bucket can be reached. Objects are listed rather than the bucket read,
+// which needs storage.buckets.get that object-level roles do not grant.
+func (g *gcs) PingBucket(ctx context.Context, bucketName string) error {
+	objects := g.storageClient.Bucket(bucketName).Objects(ctx, nil)
+	objects.PageInfo().MaxSize = 1
+	if _, err := objects.Next(); err != nil && err != iterator.Done {
+		return xerrors.Errorf("PingBucket : %+w", err)
+	}
+	return nil
+}
+
+// Close the storage client
+func (g *gcs) Close() error {
+	if g.storageClient == nil {
+		return nil
+	}
+	if err := g.storageClient.Close(); err != nil {
+		return xerrors.Errorf("Close : %+w", err)
+	}
+	return nil
+}
@@ -34,5 +34,18 @@ func TestGCSHandlerSmoke(t *testing.T) {
 			assert.Equal(t, content, string(obj.Content))
 
 		})
+
+		t.Run("PingBucket", func(t *testing.T) {
+			const bucketName = "empty-bucket"
+
+			server.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: bucketName})
+
+			ctx := context.Background()
+			gcs := NewGCS(ctx, server.Client())
+
+			// No object is needed
+			assert.Nil(t, gcs.PingBucket(ctx, bucketName))
+			assert.NotNil(t, gcs.PingBucket(ctx, "missing-bucket"))
+		})
 	})
 }
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/xerrors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+var (
+	ErrMigrationsDirty = xerrors.New("migrations are dirty")
+	ErrNoRepository    = xerrors.New("no repository to check")
+)
+
+type (
+	HealthController interface {
+		// Liveness, the process serves requests
+		Live(c echo.Context) error
+		// Readiness, every dependency is reachable
+		Ready(c echo.Context) error
+		// Startup, ready once. The dependencies are not checked any more after that.
+		Startup(c echo.Context) error
+	}
+
+	healthController struct {
+		checker *healthChecker
+		started int32
+	}
+
+	healthCheck struct {
+		name  string
+		check func(ctx context.Context) error
+	}
+
+	HealthCheckResult struct {
+		Name      string  `json:"name"`
+		Status    string  `json:"status"`
+		LatencyMs float64 `json:"latency_ms"`
+		Error     string  `json:"error,omitempty"`
+	}
+
+	HealthReport struct {
+		Status    string               `json:"status"`
+		Checks    []*HealthCheckResult `json:"checks"`
+		CheckedAt time.Time            `json:"checked_at"`
+	}
+
+	// Runs the checks in parallel, each within timeout.
+	// The report is reused for ttl so that probes do not load the dependencies.
+	healthChecker struct {
+		checks  []healthCheck
+		timeout time.Duration
+		ttl     time.Duration
+		now     func() time.Time
+
+		mu     sync.Mutex
+		report *HealthReport
+	}
+)
+
+func NewHealthController(ctx context.Context, checks []healthCheck) HealthController {
+	config := GetApplicationConfig(ctx)
+	return &healthController{
+		checker: newHealthChecker(checks,
+			time.Duration(config.HealthCheckTimeout)*time.Second,
+			time.Duration(config.HealthCacheTTL)*time.Second,
+		),
+	}
+}
+
+// Checks of the data stores of the repository. The memory store has nothing to check.
+// Without a repository, such as when only the TodoService is given, readiness fails
+// rather than reporting dependencies it has not checked.
+func newDependencyChecks(ctx context.Context, repository Repository) []healthCheck {
+	config := GetApplicationConfig(ctx)
+	if config.TodoStore == TODO_STORE_MEMORY {
+		return []healthCheck{{name: "memory", check: func(ctx context.Context) error {
+			return nil
+		}}}
+	}
+	if repository == nil {
+		return []healthCheck{{name: "repository", check: func(ctx context.Context) error {
+			return ErrNoRepository
+		}}}
+	}
+
+	return []healthCheck{
+		{name: "database", check: func(ctx context.Context) error {
+			return repository.CloudSQL().Ping(ctx)
+		}},
+		{name: "storage", check: func(ctx context.Context) error {
+			return repository.GCS().PingBucket(ctx, config.BucketName)
+		}},
+		// Not MigrationStatus, which waits for a running migration and reads the sources
+		{name: "migrations", check: func(ctx context.Context) error {
+			version, dirty, err := repository.CloudSQL().SchemaVersion(ctx)
+			if err != nil {
+				return err
+			}
+			if dirty {
+				return xerrors.Errorf("version %d : %w", version, ErrMigrationsDirty)
+			}
+			return nil
+		}},
+	}
+}
+
+// GET /healthz
+func (h *healthController) Live(c echo.Context) error {
+	return c.JSON(http.StatusOK, &HealthReport{Status: HealthStatusOK, Checks: []*HealthCheckResult{}, CheckedAt: time.Now().UTC()})
+}
+
+// GET /readyz
+// 503 Service Unavailable when a dependency fails
+func (h *healthController) Ready(c echo.Context) error {
+	report := h.checker.Run()
+	return c.JSON(report.HTTPStatus(), report)
+}
+
+// GET /startupz
+func (h *healthController) Startup(c echo.Context) error {
+	if atomic.LoadInt32(&h.started) == 1 {
+		return h.Live(c)
+	}
+
+	report := h.checker.Run()
+	if report.Status == HealthStatusOK {
+		atomic.StoreInt32(&h.started, 1)
+	}
+	return c.JSON(report.HTTPStatus(), report)
+}
+
+func newHealthChecker(checks []healthCheck, timeout time.Duration, ttl time.Duration) *healthChecker {
+	return &healthChecker{checks: checks, timeout: timeout, ttl: ttl, now: time.Now}
+}
+
+// Report of the checks. Concurrent callers wait for one run.
+func (h *healthChecker) Run() *HealthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.report != nil && h.now().Sub(h.report.CheckedAt) < h.ttl {
+		return h.report
+	}
+
+	report := &HealthReport{Status: HealthStatusOK, Checks: make([]*HealthCheckResult, len(h.checks))}
+	var wg sync.WaitGroup
+	for i, check := range h.checks {
+		wg.Add(1)
+		go func(i int, check healthCheck) {
+			defer wg.Done()
+			report.Checks[i] = h.runCheck(check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != HealthStatusOK {
+			report.Status = HealthStatusFail
+		}
+	}
+	report.CheckedAt = h.now()
+
+	h.report = report
+	return report
+}
+
+// Not bound to any request, so that a client hanging up does not fail the cached report
+func (h *healthChecker) runCheck(check healthCheck) *HealthCheckResult {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	start := h.now()
+	// A check which ignores ctx is abandoned on timeout
+	done := make(chan error, 1)
+	go func() {
+		done <- check.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := &HealthCheckResult{
+		Name:      check.name,
+		Status:    HealthStatusOK,
+		LatencyMs: float64(h.now().Sub(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = HealthStatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (r *HealthReport) HTTPStatus() int {
+	if r.Status != HealthStatusOK {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
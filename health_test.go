@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthChecker(t *testing.T) {
+	t.Parallel()
+
+	ok := func(ctx context.Context) error { return nil }
+	fail := func(ctx context.Context) error { return ErrMigrationsDirty }
+
+	t.Run("Fails when a check fails", func(t *testing.T) {
+		t.Parallel()
+
+		report := newHealthChecker([]healthCheck{{name: "a", check: ok}, {name: "b", check: fail}}, time.Second, 0).Run()
+		assert.Equal(t, HealthStatusFail, report.Status)
+		assert.Equal(t, http.StatusServiceUnavailable, report.HTTPStatus())
+		assert.Equal(t, "a", report.Checks[0].Name)
+		assert.Equal(t, HealthStatusOK, report.Checks[0].Status)
+		assert.Equal(t, HealthStatusFail, report.Checks[1].Status)
+		assert.Equal(t, "migrations are dirty", report.Checks[1].Error)
+
+		report = newHealthChecker([]healthCheck{{name: "a", check: ok}}, time.Second, 0).Run()
+		assert.Equal(t, HealthStatusOK, report.Status)
+		assert.Equal(t, http.StatusOK, report.HTTPStatus())
+	})
+
+	t.Run("Times out", func(t *testing.T) {
+		t.Parallel()
+
+		hang := func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		}
+		report := newHealthChecker([]healthCheck{{name: "hang", check: hang}}, 50*time.Millisecond, 0).Run()
+		assert.Equal(t, HealthStatusFail, report.Status)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+		assert.Less(t, report.Checks[0].LatencyMs, float64(1000))
+	})
+
+	t.Run("Caches the report", func(t *testing.T) {
+		t.Parallel()
+
+		var runs int32
+		counted := func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		}
+		checker := newHealthChecker([]healthCheck{{name: "counted", check: counted}}, time.Second, time.Minute)
+		now := time.Now()
+		checker.now = func() time.Time { return now }
+
+		checker.Run()
+		checker.Run()
+		assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+
+		now = now.Add(time.Minute)
+		checker.Run()
+		assert.Equal(t, int32(2), atomic.LoadInt32(&runs))
+	})
+}
+
+func TestHealthController(t *testing.T) {
+	t.Helper()
+
+	get := func(t *testing.T, handler echo.HandlerFunc) (int, *HealthReport) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		assert.Nil(t, handler(echo.New().NewContext(req, rec)))
+
+		report := &HealthReport{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), report))
+		return rec.Code, report
+	}
+
+	t.Run("Startup latches once ready", func(t *testing.T) {
+		var healthy int32
+		h := &healthController{checker: newHealthChecker([]healthCheck{{name: "flaky", check: func(ctx context.Context) error {
+			if atomic.LoadInt32(&healthy) == 0 {
+				return ErrMigrationsDirty
+			}
+			return nil
+		}}}, time.Second, 0)}
+
+		code, report := get(t, h.Live)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, HealthStatusOK, report.Status)
+
+		code, report = get(t, h.Startup)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "flaky", report.Checks[0].Name)
+
+		atomic.StoreInt32(&healthy, 1)
+		code, _ = get(t, h.Startup)
+		assert.Equal(t, http.StatusOK, code)
+
+		// Readiness keeps checking, startup does not
+		atomic.StoreInt32(&healthy, 0)
+		code, _ = get(t, h.Ready)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		code, _ = get(t, h.Startup)
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("Checks the repository", eachTestWrapper(func(t *testing.T) {
+		skipWithoutDatabase(t)
+		ctx := context.Background()
+
+		server, err := fakestorage.NewServerWithOptions(fakestorage.Options{NoListener: true})
+		assert.Nil(t, err)
+		server.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: GetApplicationConfig(ctx).BucketName})
+
+		repository, err := NewRepository(ctx, server.Client())
+		assert.Nil(t, err)
+		h := NewHealthController(ctx, newDependencyChecks(ctx, repository))
+		code, report := get(t, h.Ready)
+		assert.Equal(t, http.StatusOK, code, report)
+		assert.Len(t, report.Checks, 3)
+
+		// Not ready rather than unchecked without a repository
+		code, report = get(t, NewHealthController(ctx, newDependencyChecks(ctx, nil)).Ready)
+		assert.Equal(t, http.StatusServiceUnavailable, code, report)
+		assert.Equal(t, ErrNoRepository.Error(), report.Checks[0].Error)
+	}))
+}
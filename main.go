@@ -5,39 +5,272 @@ import (
 	"github.com/glassonion1/logz"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/otel"
+	"golang.org/x/xerrors"
+	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// Polling interval of waitForSchema
+	schemaPollInterval = 500 * time.Millisecond
+)
+
+type (
+	RouterOption func(*components)
+
+	// Components wired by NewRouter
+	components struct {
+		repository       Repository
+		todoService      TodoService
+		todoController   TodoController
+		healthController HealthController
+	}
 )
 
 func main() {
-	ctx := context.Background()
+	os.Exit(runCLI(context.Background(), os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// Serve until SIGTERM or SIGINT, then drain the requests in flight
+// and release the connections before returning.
+func serve(ctx context.Context) error {
 	config := GetApplicationConfig(ctx)
 
 	logz.InitTracer()
+	defer func() {
+		if err := flushTracer(ctx); err != nil {
+			logz.Errorf(ctx, "Failed to flush the tracer. %+v\n", err)
+		}
+	}()
+
+	var opts []RouterOption
+	if config.TodoStore != TODO_STORE_MEMORY {
+		// Shared by every service
+		repository, err := NewRepository(ctx, nil)
+		if err != nil {
+			return xerrors.Errorf("serve : %+w", err)
+		}
+		// Closed after the server has drained
+		defer func() {
+			if err := repository.Close(); err != nil {
+				logz.Errorf(ctx, "Failed to close the repository. %+v\n", err)
+			}
+		}()
+		if config.MigrateOnStartup {
+			// Exits non-zero rather than serving a schema which is known to be broken
+			timeout := time.Duration(config.MigrationLockTimeout) * time.Second
+			if err := migrateOnStartup(ctx, repository.CloudSQL(), timeout); err != nil {
+				return xerrors.Errorf("serve : %+w", err)
+			}
+		}
+		opts = append(opts, WithRepository(repository))
+	}
+
+	router, err := NewRouter(ctx, opts...)
+	if err != nil {
+		return xerrors.Errorf("serve : %+w", err)
+	}
+
+	signalCtx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	grace := time.Duration(config.ShutdownGracePeriod) * time.Second
+	return runServer(signalCtx, router, ":"+strconv.Itoa(config.Port), grace)
+}
+
+// Start the server and shut it down when ctx is done. It stops accepting connections
+// and waits up to grace for the requests in flight.
+func runServer(ctx context.Context, e *echo.Echo, address string, grace time.Duration) error {
+	started := make(chan error, 1)
+	go func() {
+		started <- e.Start(address)
+	}()
+
+	select {
+	case err := <-started:
+		return xerrors.Errorf("runServer : %+w", err)
+	case <-ctx.Done():
+	}
+
+	logz.Infof(ctx, "Shutting down, waiting %s for requests in flight", grace)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		return xerrors.Errorf("runServer : %+w", err)
+	}
+	return nil
+}
+
+// Export the spans still buffered by the tracer provider of logz.InitTracer
+func flushTracer(ctx context.Context) error {
+	provider, ok := otel.GetTracerProvider().(interface {
+		Shutdown(ctx context.Context) error
+	})
+	if !ok {
+		return nil
+	}
+	return provider.Shutdown(ctx)
+}
+
+// Apply pending migrations before serving. An instance which does not get the lock
+// in time waits up to timeout more for the instance holding it to finish, so that
+// no instance serves a schema in the middle of a migration.
+func migrateOnStartup(ctx context.Context, cloudSQL CloudSQL, timeout time.Duration) error {
+	err := cloudSQL.MigrateUpLocked(ctx, timeout)
+	if xerrors.Is(err, ErrMigrationLocked) {
+		logz.Infof(ctx, "Another instance is migrating, waiting for it. %+v", err)
+		err = waitForSchema(ctx, cloudSQL, timeout)
+	}
+	if err != nil {
+		return xerrors.Errorf("migrateOnStartup : %+w", err)
+	}
 
-	router := NewRouter(ctx)
+	logz.Infof(ctx, "Migrated on startup")
+	return nil
+}
+
+// Poll the schema version until every migration has been applied
+func waitForSchema(ctx context.Context, cloudSQL CloudSQL, timeout time.Duration) error {
+	status, err := cloudSQL.MigrationStatus(ctx)
+	if err != nil {
+		return xerrors.Errorf("waitForSchema : %+w", err)
+	}
+	target := status.Version
+	if 0 < len(status.Pending) {
+		target = status.Pending[len(status.Pending)-1]
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		version, dirty, err := cloudSQL.SchemaVersion(ctx)
+		if err != nil {
+			return xerrors.Errorf("waitForSchema : %+w", err)
+		}
+		// The other instance failed half way
+		if dirty {
+			return xerrors.Errorf("waitForSchema : version %d : %w", version, ErrMigrationsDirty)
+		}
+		if target <= version {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return xerrors.Errorf("waitForSchema : version %d of %d : %w", version, target, ErrMigrationLocked)
+		}
+
+		select {
+		case <-ctx.Done():
+			return xerrors.Errorf("waitForSchema : %+w", ctx.Err())
+		case <-time.After(schemaPollInterval):
+		}
+	}
+}
 
-	// Start server
-	router.Logger.Fatal(router.Start(":" + strconv.Itoa(config.Port)))
+// Use the given Repository instead of connecting to the data stores
+func WithRepository(repository Repository) RouterOption {
+	return func(c *components) {
+		c.repository = repository
+	}
 }
 
-func NewRouter(ctx context.Context) *echo.Echo {
+// Use the given TodoService instead of the one built on the Repository
+func WithTodoService(todoService TodoService) RouterOption {
+	return func(c *components) {
+		c.todoService = todoService
+	}
+}
+
+// Use the given TodoController instead of the one built on the TodoService
+func WithTodoController(todoController TodoController) RouterOption {
+	return func(c *components) {
+		c.todoController = todoController
+	}
+}
+
+// Use the given HealthController instead of the one checking the Repository
+func WithHealthController(healthController HealthController) RouterOption {
+	return func(c *components) {
+		c.healthController = healthController
+	}
+}
+
+// Composition root. One Repository is built and shared by every service,
+// the components which are not given by the options are built with the defaults.
+// TODO_STORE=memory builds the TodoService on the memory store and no Repository.
+func NewRouter(ctx context.Context, opts ...RouterOption) (*echo.Echo, error) {
+	c := &components{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.todoController == nil {
+		if c.todoService == nil {
+			todoService, err := newTodoService(ctx, c)
+			if err != nil {
+				return nil, xerrors.Errorf("NewRouter : %+w", err)
+			}
+			c.todoService = todoService
+		}
+		c.todoController = NewTodoController(ctx, c.todoService)
+	}
+	todoController := c.todoController
+
+	// The data stores of the Repository are checked, none in memory mode
+	if c.healthController == nil {
+		c.healthController = NewHealthController(ctx, newDependencyChecks(ctx, c.repository))
+	}
+	healthController := c.healthController
+
+	config := GetApplicationConfig(ctx)
+
 	// Echo instance
 	e := echo.New()
+	e.HTTPErrorHandler = ProblemErrorHandler
+	e.Validator = NewValidator()
+	applyServerTimeouts(ctx, e.Server)
 
 	// Middleware
 	e.Use(middleware.Logger())
+	e.Use(TraceMiddleware(config.ImageName))
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
 	e.Use(middleware.RateLimiter(middleware.NewRateLimiterMemoryStore(100)))
+	// Batches may take up to TIMEOUT
+	e.Use(TimeoutMiddleware(NewTimeoutConfig(ctx, "POST /v1/todos:action", "POST /todos:action")))
 
-	todoController := NewTodoController(ctx)
+	// Probes
+	e.GET("/healthz", healthController.Live)
+	e.GET("/readyz", healthController.Ready)
+	e.GET("/startupz", healthController.Startup)
 
 	// Routes
-	e.GET("/todos", todoController.List)
-	e.GET("/:id", todoController.Get)
-	e.POST("/", todoController.Create)
-	e.DELETE("/:id", todoController.Delete)
-	e.PUT("/", todoController.Update)
+	registerTodoRoutesV1(e.Group("/v1"), todoController)
+	if config.LegacyRoutes {
+		registerLegacyTodoRoutes(e, todoController, DeprecationMiddleware(DeprecationConfig{
+			Deprecation: legacyRoutesDeprecation,
+			Sunset:      config.LegacyRoutesSunset,
+			Successor:   "/v1/todos",
+		}))
+	}
+
+	return e, nil
+}
+
+// TodoService of the store selected by TODO_STORE
+func newTodoService(ctx context.Context, c *components) (TodoService, error) {
+	if GetApplicationConfig(ctx).TodoStore == TODO_STORE_MEMORY {
+		return NewMemoryTodoService(ctx, GetMemoryTodoStore()), nil
+	}
 
-	return e
+	if c.repository == nil {
+		repository, err := NewRepository(ctx, nil)
+		if err != nil {
+			return nil, xerrors.Errorf("newTodoService : %+w", err)
+		}
+		c.repository = repository
+	}
+	return NewTodoService(ctx, c.repository), nil
 }
@@ -5,19 +5,40 @@ import (
 	"fmt"
 	"github.com/docker/go-connections/nat"
 	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
+	"golang.org/x/xerrors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 // Common Test Setting
 func TestMain(m *testing.M) {
 
 	// Place all MySQL related tests as sum test of this parent test
-	// so that only one Instance up and test against it
-	_, mysqlTerm := initMySQLContainer()
-	defer mysqlTerm()
+	// so that only one Instance up and test against it.
+	// DB_DRIVER=postgres runs them against PostgreSQL instead.
+	// TODO_STORE=memory and DB_DRIVER=sqlite run the tests without Docker.
+	// The config is not read here since it is loaded only once, after the database is up.
+	if os.Getenv("TODO_STORE") == TODO_STORE_MEMORY {
+		// No database
+	} else if os.Getenv("DB_DRIVER") == DB_DRIVER_SQLITE {
+		sqliteTerm := initSQLiteDatabase()
+		defer sqliteTerm()
+	} else if os.Getenv("DB_DRIVER") == DB_DRIVER_POSTGRES {
+		_, postgresTerm := initPostgresContainer()
+		defer postgresTerm()
+	} else {
+		_, mysqlTerm := initMySQLContainer()
+		defer mysqlTerm()
+	}
 
 	// Run tests
 	m.Run()
@@ -78,6 +99,69 @@ func initMySQLContainer() (string, func()) {
 	return dataSourceName, cTerm
 }
 
+// Generate dsn and close function for test use.
+// *** DO NOT USE FOR PRODUCTION ***
+func initPostgresContainer() (string, func()) {
+	ctx := context.Background()
+	username := "postgres"
+	password := "password"
+	req := testcontainers.ContainerRequest{
+		Image:        "postgres:13",
+		ExposedPorts: []string{"5432/tcp"},
+		Env: map[string]string{
+			"POSTGRES_PASSWORD": password,
+			"POSTGRES_DB":       "test",
+		},
+		// The server restarts once after the init scripts
+		WaitingFor: wait.ForLog("database system is ready to accept connections").WithOccurrence(2),
+	}
+	postgresC, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	ip, err := postgresC.Host(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	port, err := postgresC.MappedPort(ctx, "5432")
+	if err != nil {
+		panic(err)
+	}
+
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USERNAME", username)
+	os.Setenv("DB_PASSWORD", password)
+	os.Setenv("DB_IP", ip)
+	os.Setenv("DB_PORT", port.Port())
+
+	dataSourceName := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=test sslmode=disable", ip, port.Int(), username, password)
+	fmt.Println(dataSourceName)
+	cTerm := func() {
+		defer postgresC.Terminate(ctx)
+	}
+
+	return dataSourceName, cTerm
+}
+
+// Point DB_NAME to a database file in a temporary directory, removed by the returned function
+func initSQLiteDatabase() func() {
+	dir, err := ioutil.TempDir("", "go-cloudrun-boilerplate")
+	if err != nil {
+		panic(err)
+	}
+
+	os.Setenv("DB_NAME", filepath.Join(dir, "test"))
+
+	return func() {
+		os.RemoveAll(dir)
+	}
+}
+
 // Test gcs Server wrapper
 func runServersTest(t *testing.T, objs []fakestorage.Object, fn func(*testing.T, *fakestorage.Server)) {
 
@@ -102,14 +186,237 @@ func runServersTest(t *testing.T, objs []fakestorage.Object, fn func(*testing.T,
 
 type testFunc func(t *testing.T)
 
+// Run fn on empty todos of the store selected by TODO_STORE
 func eachTestWrapper(fn func(t *testing.T)) func(t *testing.T) {
 	return func(t *testing.T) {
 		ctx := context.Background()
-		dao := NewCloudSQL(ctx)
+		if GetApplicationConfig(ctx).TodoStore == TODO_STORE_MEMORY {
+			GetMemoryTodoStore().Reset()
+			fn(t)
+			GetMemoryTodoStore().Reset()
+			return
+		}
+
+		dao := newTestCloudSQL(t, ctx)
 
-		_ = dao.StartMigrations(ctx)
+		// Apply all migrations one by one
+		for dao.StartMigrations(ctx) == nil {
+		}
 		fn(t)
-		_ = dao.RollbackLastMigrations(ctx)
+		// Roll back all migrations one by one
+		for dao.RollbackLastMigrations(ctx) == nil {
+		}
 		return
 	}
 }
+
+// NewCloudSQL failing the test when the database can not be opened
+func newTestCloudSQL(t *testing.T, ctx context.Context) CloudSQL {
+	t.Helper()
+	cloudSQL, err := NewCloudSQL(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cloudSQL
+}
+
+// NewRouter failing the test when a component can not be built
+func newTestRouter(t *testing.T, ctx context.Context, opts ...RouterOption) *echo.Echo {
+	t.Helper()
+	router, err := NewRouter(ctx, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+// TodoService of TODO_STORE failing the test when the store can not be opened
+func newTestTodoService(t *testing.T, ctx context.Context) TodoService {
+	t.Helper()
+	todoService, err := newTodoService(ctx, &components{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return todoService
+}
+
+// Skip tests which need a database
+func skipWithoutDatabase(t *testing.T) {
+	t.Helper()
+	if GetApplicationConfig(context.Background()).TodoStore == TODO_STORE_MEMORY {
+		t.Skip("no database with TODO_STORE=memory")
+	}
+}
+
+// Skip tests which depend on MySQL behavior
+func skipUnlessMySQL(t *testing.T) {
+	t.Helper()
+	skipWithoutDatabase(t)
+	if driver := GetApplicationConfig(context.Background()).DBDriver; driver != DB_DRIVER_MYSQL {
+		t.Skipf("MySQL is not running with DB_DRIVER=%s", driver)
+	}
+}
+
+type (
+	// Panics on any method but the overridden ones
+	fakeRepository struct {
+		Repository
+	}
+
+	fakeTodoService struct {
+		TodoService
+		todos map[int64]*Todo
+	}
+
+	fakeCloudSQL struct {
+		CloudSQL
+		migrateErr error
+		// Versions SchemaVersion returns one by one, the last one is kept
+		versions []uint
+		dirty    bool
+	}
+)
+
+func (f *fakeCloudSQL) MigrateUpLocked(ctx context.Context, timeout time.Duration) error {
+	return f.migrateErr
+}
+
+func (f *fakeCloudSQL) MigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	return &MigrationStatus{Version: f.versions[0], Pending: []uint{4, 5}}, nil
+}
+
+func (f *fakeCloudSQL) SchemaVersion(ctx context.Context) (uint, bool, error) {
+	version := f.versions[0]
+	if 1 < len(f.versions) {
+		f.versions = f.versions[1:]
+	}
+	return version, f.dirty, nil
+}
+
+func (f *fakeTodoService) Get(ctx context.Context, id int64) (*Todo, error) {
+	todo, ok := f.todos[id]
+	if !ok {
+		return nil, ErrTodoNotFound
+	}
+	return todo, nil
+}
+
+func TestMigrateOnStartup(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	assert.Nil(t, migrateOnStartup(ctx, &fakeCloudSQL{}, time.Second))
+
+	locked := xerrors.Errorf(": %+w", ErrMigrationLocked)
+
+	// Another instance is migrating, waited for until it is done
+	assert.Nil(t, migrateOnStartup(ctx, &fakeCloudSQL{migrateErr: locked, versions: []uint{3, 4, 5}}, time.Second))
+
+	// It does not finish in time
+	err := migrateOnStartup(ctx, &fakeCloudSQL{migrateErr: locked, versions: []uint{3}}, time.Millisecond)
+	assert.True(t, xerrors.Is(err, ErrMigrationLocked))
+
+	// It failed half way
+	err = migrateOnStartup(ctx, &fakeCloudSQL{migrateErr: locked, versions: []uint{3, 4}, dirty: true}, time.Second)
+	assert.True(t, xerrors.Is(err, ErrMigrationsDirty))
+
+	err = migrateOnStartup(ctx, &fakeCloudSQL{migrateErr: ErrInvalidParameter}, time.Second)
+	assert.True(t, xerrors.Is(err, ErrInvalidParameter))
+}
+
+func TestRunServer(t *testing.T) {
+	t.Parallel()
+
+	// Router with a request in flight when ctx is canceled
+	start := func(t *testing.T, sleep time.Duration, grace time.Duration) (*http.Response, error) {
+		e := echo.New()
+		e.HideBanner, e.HidePort = true, true
+		e.GET("/slow", func(c echo.Context) error {
+			time.Sleep(sleep)
+			return c.String(http.StatusOK, "done")
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- runServer(ctx, e, "127.0.0.1:0", grace)
+		}()
+		for e.ListenerAddr() == nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		responded := make(chan *http.Response, 1)
+		go func() {
+			res, err := http.Get("http://" + e.ListenerAddr().String() + "/slow")
+			if err != nil {
+				t.Log(err)
+			}
+			responded <- res
+		}()
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+
+		err := <-stopped
+		return <-responded, err
+	}
+
+	t.Run("Drains requests in flight", func(t *testing.T) {
+		t.Parallel()
+
+		res, err := start(t, 200*time.Millisecond, time.Second)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("Gives up after the grace period", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := start(t, time.Second, 100*time.Millisecond)
+		assert.True(t, xerrors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("Fails to listen", func(t *testing.T) {
+		t.Parallel()
+
+		e := echo.New()
+		e.HideBanner = true
+		err := runServer(context.Background(), e, "127.0.0.1:-1", time.Second)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestNewRouter(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("Inject fakes", func(t *testing.T) {
+		t.Parallel()
+
+		router := newTestRouter(t, ctx,
+			WithRepository(&fakeRepository{}),
+			WithTodoService(&fakeTodoService{todos: map[int64]*Todo{1: {ID: 1, Task: "fake", Version: 1}}}),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/todos/1", nil)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "\"task\":\"fake\"")
+
+		req = httptest.NewRequest(http.MethodGet, "/v1/todos/2", nil)
+		rec = httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		rec = httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"github.com/golang-migrate/migrate/source"
+	"golang.org/x/xerrors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+type (
+	// golang-migrate source driver reading every migration into memory from a fs.FS.
+	// golang-migrate v3 has no iofs source.
+	fsMigrateSource struct {
+		dir        string
+		migrations *source.Migrations
+		bodies     map[string][]byte
+	}
+)
+
+// Migrations of every DB_DRIVER, shipped in the binary
+//
+//go:embed migrations
+var embeddedMigrations embed.FS
+
+// Migrations of dir, which is a directory of fsys
+func newFSMigrateSource(fsys fs.FS, dir string) (source.Driver, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, xerrors.Errorf("newFSMigrateSource : %+w", err)
+	}
+
+	s := &fsMigrateSource{
+		dir:        dir,
+		migrations: source.NewMigrations(),
+		bodies:     map[string][]byte{},
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		m, err := source.DefaultParse(entry.Name())
+		if err != nil {
+			// Not a migration
+			continue
+		}
+		if !s.migrations.Append(m) {
+			return nil, xerrors.Errorf("unable to parse file %v", entry.Name())
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, xerrors.Errorf("newFSMigrateSource : %+w", err)
+		}
+		s.bodies[m.Raw] = body
+	}
+	return s, nil
+}
+
+// Only made by newFSMigrateSource
+func (s *fsMigrateSource) Open(url string) (source.Driver, error) {
+	return nil, xerrors.Errorf("fs source can not be opened with a URL : %s", url)
+}
+
+func (s *fsMigrateSource) Close() error {
+	return nil
+}
+
+// The ends of the migrations are returned as a bare *os.PathError,
+// migrate checks them with os.IsNotExist which does not unwrap
+func (s *fsMigrateSource) First() (version uint, err error) {
+	if version, ok := s.migrations.First(); ok {
+		return version, nil
+	}
+	return 0, &os.PathError{Op: "first", Path: s.dir, Err: os.ErrNotExist}
+}
+
+func (s *fsMigrateSource) Prev(version uint) (prevVersion uint, err error) {
+	if prevVersion, ok := s.migrations.Prev(version); ok {
+		return prevVersion, nil
+	}
+	return 0, &os.PathError{Op: fmt.Sprintf("prev for version %v", version), Path: s.dir, Err: os.ErrNotExist}
+}
+
+func (s *fsMigrateSource) Next(version uint) (nextVersion uint, err error) {
+	if nextVersion, ok := s.migrations.Next(version); ok {
+		return nextVersion, nil
+	}
+	return 0, &os.PathError{Op: fmt.Sprintf("next for version %v", version), Path: s.dir, Err: os.ErrNotExist}
+}
+
+func (s *fsMigrateSource) ReadUp(version uint) (r io.ReadCloser, identifier string, err error) {
+	if m, ok := s.migrations.Up(version); ok {
+		return ioutil.NopCloser(bytes.NewReader(s.bodies[m.Raw])), m.Identifier, nil
+	}
+	return nil, "", &os.PathError{Op: fmt.Sprintf("read version %v", version), Path: s.dir, Err: os.ErrNotExist}
+}
+
+func (s *fsMigrateSource) ReadDown(version uint) (r io.ReadCloser, identifier string, err error) {
+	if m, ok := s.migrations.Down(version); ok {
+		return ioutil.NopCloser(bytes.NewReader(s.bodies[m.Raw])), m.Identifier, nil
+	}
+	return nil, "", &os.PathError{Op: fmt.Sprintf("read version %v", version), Path: s.dir, Err: os.ErrNotExist}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFSMigrateSource(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Reads migrations in version order", func(t *testing.T) {
+		t.Parallel()
+
+		src, err := newFSMigrateSource(fstest.MapFS{
+			"m/10_b.up.sql":   {Data: []byte("up 10")},
+			"m/10_b.down.sql": {Data: []byte("down 10")},
+			"m/2_a.up.sql":    {Data: []byte("up 2")},
+			"m/README.md":     {Data: []byte("not a migration")},
+		}, "m")
+		assert.Nil(t, err)
+
+		version, err := src.First()
+		assert.Nil(t, err)
+		assert.Equal(t, uint(2), version)
+
+		version, err = src.Next(version)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(10), version)
+
+		_, err = src.Next(version)
+		assert.True(t, os.IsNotExist(err))
+
+		r, identifier, err := src.ReadDown(10)
+		assert.Nil(t, err)
+		assert.Equal(t, "b", identifier)
+		body, _ := ioutil.ReadAll(r)
+		assert.Equal(t, "down 10", string(body))
+
+		_, _, err = src.ReadDown(2)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Embeds the migrations of every driver", func(t *testing.T) {
+		t.Parallel()
+
+		for _, driver := range []string{DB_DRIVER_MYSQL, DB_DRIVER_POSTGRES, DB_DRIVER_SQLITE} {
+			embedded, err := newFSMigrateSource(embeddedMigrations, "migrations/"+driver)
+			assert.Nil(t, err)
+			onDisk, err := newFSMigrateSource(os.DirFS("migrations"), driver)
+			assert.Nil(t, err)
+			assert.Equal(t, onDisk.(*fsMigrateSource).bodies, embedded.(*fsMigrateSource).bodies)
+			assert.Len(t, embedded.(*fsMigrateSource).bodies, 10)
+		}
+	})
+
+	t.Run("Unknown directory", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newFSMigrateSource(embeddedMigrations, "migrations/oracle")
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/golang-migrate/migrate/database"
+	"io"
+	"io/ioutil"
+	nurl "net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	sqliteMigrationsTable = migrationsTable
+)
+
+type (
+	// golang-migrate database driver on the pure Go SQLite driver.
+	// The sqlite3 driver of golang-migrate v3 needs cgo.
+	sqliteMigrateDriver struct {
+		mu       sync.Mutex
+		db       *sql.DB
+		isLocked bool
+	}
+)
+
+// Driver migrating the given database. The database is not closed by the driver.
+func newSQLiteMigrateDriver(db *sql.DB) (database.Driver, error) {
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	d := &sqliteMigrateDriver{db: db}
+	query := "CREATE TABLE IF NOT EXISTS " + sqliteMigrationsTable + " (version INTEGER NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)"
+	if _, err := db.Exec(query); err != nil {
+		return nil, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+	return d, nil
+}
+
+// sqlite://path/to/file.db
+func (d *sqliteMigrateDriver) Open(url string) (database.Driver, error) {
+	purl, err := nurl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(DB_DRIVER_SQLITE, strings.TrimPrefix(url, purl.Scheme+"://"))
+	if err != nil {
+		return nil, err
+	}
+	return newSQLiteMigrateDriver(db)
+}
+
+// The database is owned by cloudSQL
+func (d *sqliteMigrateDriver) Close() error {
+	return nil
+}
+
+// Only one process writes to the file, locking in the process is enough
+func (d *sqliteMigrateDriver) Lock() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.isLocked {
+		return database.ErrLocked
+	}
+	d.isLocked = true
+	return nil
+}
+
+func (d *sqliteMigrateDriver) Unlock() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.isLocked = false
+	return nil
+}
+
+// Run the whole migration in a transaction, SQLite rolls DDL back as well
+func (d *sqliteMigrateDriver) Run(migration io.Reader) error {
+	body, err := ioutil.ReadAll(migration)
+	if err != nil {
+		return err
+	}
+
+	return d.inTx(string(body))
+}
+
+func (d *sqliteMigrateDriver) SetVersion(version int, dirty bool) error {
+	query := "DELETE FROM " + sqliteMigrationsTable + ";"
+	if version >= 0 {
+		query += fmt.Sprintf(" INSERT INTO %s (version, dirty) VALUES (%d, %t);", sqliteMigrationsTable, version, dirty)
+	}
+	return d.inTx(query)
+}
+
+func (d *sqliteMigrateDriver) Version() (version int, dirty bool, err error) {
+	query := "SELECT version, dirty FROM " + sqliteMigrationsTable + " LIMIT 1"
+	err = d.db.QueryRow(query).Scan(&version, &dirty)
+	switch {
+	case err == sql.ErrNoRows:
+		return database.NilVersion, false, nil
+	case err != nil:
+		return 0, false, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+	return version, dirty, nil
+}
+
+// Drop every table with its indexes and triggers
+func (d *sqliteMigrateDriver) Drop() error {
+	// Virtual tables first, which drop their shadow tables
+	query := "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY sql LIKE 'CREATE VIRTUAL%' DESC"
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+	defer rows.Close()
+
+	var drops []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		drops = append(drops, fmt.Sprintf("DROP TABLE IF EXISTS %q;", name))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if len(drops) == 0 {
+		return nil
+	}
+	if err := d.inTx(strings.Join(drops, " ")); err != nil {
+		return err
+	}
+
+	_, err = newSQLiteMigrateDriver(d.db)
+	return err
+}
+
+func (d *sqliteMigrateDriver) inTx(query string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return &database.Error{OrigErr: err, Err: "transaction start failed"}
+	}
+	if _, err := tx.Exec(query); err != nil {
+		tx.Rollback()
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+	if err := tx.Commit(); err != nil {
+		return &database.Error{OrigErr: err, Err: "transaction commit failed"}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql"
+	"github.com/golang-migrate/migrate/database"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSQLiteMigrateDriver(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "go-cloudrun-boilerplate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := sql.Open(DB_DRIVER_SQLITE, filepath.Join(dir, "migrate.db"))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	driver, err := newSQLiteMigrateDriver(db)
+	assert.Nil(t, err)
+
+	t.Run("Version", func(t *testing.T) {
+		version, dirty, err := driver.Version()
+		assert.Nil(t, err)
+		assert.Equal(t, database.NilVersion, version)
+		assert.False(t, dirty)
+
+		assert.Nil(t, driver.SetVersion(3, true))
+		version, dirty, err = driver.Version()
+		assert.Nil(t, err)
+		assert.Equal(t, 3, version)
+		assert.True(t, dirty)
+	})
+
+	t.Run("Run and Drop", func(t *testing.T) {
+		assert.Nil(t, driver.Run(strings.NewReader("CREATE TABLE a (id INTEGER); CREATE VIRTUAL TABLE b USING fts5(c);")))
+
+		// Rolled back as a whole
+		assert.NotNil(t, driver.Run(strings.NewReader("CREATE TABLE d (id INTEGER); syntax error;")))
+
+		assert.Nil(t, driver.Drop())
+
+		var n int
+		assert.Nil(t, db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name <> ?", sqliteMigrationsTable).Scan(&n))
+		assert.Equal(t, 0, n)
+
+		version, _, err := driver.Version()
+		assert.Nil(t, err)
+		assert.Equal(t, database.NilVersion, version)
+	})
+
+	t.Run("Lock", func(t *testing.T) {
+		assert.Nil(t, driver.Lock())
+		assert.Equal(t, database.ErrLocked, driver.Lock())
+		assert.Nil(t, driver.Unlock())
+		assert.Nil(t, driver.Lock())
+		assert.Nil(t, driver.Unlock())
+	})
+}
@@ -1 +0,0 @@
-DROP TRIGGER todos.before_insert_todos;
\ No newline at end of file
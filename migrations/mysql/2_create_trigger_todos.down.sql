@@ -0,0 +1 @@
+DROP TRIGGER IF EXISTS before_insert_todos;
\ No newline at end of file
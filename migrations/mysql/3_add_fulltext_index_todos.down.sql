@@ -0,0 +1 @@
+ALTER TABLE todos DROP INDEX todos_task_fulltext;
@@ -0,0 +1 @@
+ALTER TABLE todos ADD FULLTEXT INDEX todos_task_fulltext (task);
@@ -0,0 +1 @@
+ALTER TABLE todos ADD COLUMN deleted_at TIMESTAMP NULL DEFAULT NULL, ADD KEY (deleted_at);
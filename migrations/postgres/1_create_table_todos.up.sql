@@ -0,0 +1,13 @@
+-- Timestamps have no time zone, the DSN sets TimeZone=UTC like MySQL stores them.
+CREATE TABLE todos (
+   id BIGSERIAL NOT NULL,
+   slug VARCHAR (50) NOT NULL,
+   task TEXT NOT NULL,
+   status BOOLEAN DEFAULT false,
+   created_at TIMESTAMP (0) DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP (0) DEFAULT CURRENT_TIMESTAMP,
+   CONSTRAINT todos_pk PRIMARY KEY (id)
+);
+CREATE INDEX todos_slug ON todos (slug);
+CREATE INDEX todos_status ON todos (status);
+COMMENT ON TABLE todos IS 'Todo';
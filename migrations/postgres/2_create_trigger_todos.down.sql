@@ -0,0 +1 @@
+ALTER TABLE todos ALTER COLUMN slug DROP DEFAULT;
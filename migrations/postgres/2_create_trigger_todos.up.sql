@@ -0,0 +1,4 @@
+-- The column default replaces the uuid() trigger of MySQL.
+-- It only applies when the INSERT leaves the slug out.
+-- gen_random_uuid() is built in since PostgreSQL 13.
+ALTER TABLE todos ALTER COLUMN slug SET DEFAULT gen_random_uuid()::text;
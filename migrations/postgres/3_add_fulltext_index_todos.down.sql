@@ -0,0 +1 @@
+DROP INDEX IF EXISTS todos_task_fulltext;
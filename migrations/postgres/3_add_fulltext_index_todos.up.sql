@@ -0,0 +1,2 @@
+-- Queries have to use the same expression to hit the index
+CREATE INDEX todos_task_fulltext ON todos USING GIN (to_tsvector('simple', task));
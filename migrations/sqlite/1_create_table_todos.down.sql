@@ -0,0 +1 @@
+DROP TABLE todos;
\ No newline at end of file
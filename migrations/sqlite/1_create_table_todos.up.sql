@@ -0,0 +1,12 @@
+-- Timestamps are text in the format the driver writes with _time_format=sqlite,
+-- so that they compare in chronological order.
+CREATE TABLE todos (
+   id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+   slug VARCHAR (50) NOT NULL DEFAULT '',
+   task TEXT NOT NULL,
+   status BOOLEAN DEFAULT false,
+   created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now')),
+   updated_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now'))
+);
+CREATE INDEX todos_slug ON todos (slug);
+CREATE INDEX todos_status ON todos (status);
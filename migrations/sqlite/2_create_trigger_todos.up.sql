@@ -0,0 +1,14 @@
+-- SQLite has neither uuid() nor BEFORE triggers which can change NEW,
+-- so the slug is overwritten with a random UUID v4 right after the insert.
+CREATE TRIGGER after_insert_todos
+    AFTER INSERT ON todos
+    FOR EACH ROW
+BEGIN
+    UPDATE todos SET slug = lower(
+        hex(randomblob(4)) || '-' ||
+        hex(randomblob(2)) || '-' ||
+        '4' || substr(hex(randomblob(2)), 2) || '-' ||
+        substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' ||
+        hex(randomblob(6))
+    ) WHERE id = NEW.id;
+END;
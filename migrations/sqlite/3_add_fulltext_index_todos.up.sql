@@ -0,0 +1,14 @@
+-- FTS5 index over task, kept in sync with todos by the triggers
+CREATE VIRTUAL TABLE todos_fts USING fts5(task, content='todos', content_rowid='id');
+INSERT INTO todos_fts (todos_fts) VALUES ('rebuild');
+
+CREATE TRIGGER todos_fts_insert AFTER INSERT ON todos BEGIN
+    INSERT INTO todos_fts (rowid, task) VALUES (NEW.id, NEW.task);
+END;
+CREATE TRIGGER todos_fts_delete AFTER DELETE ON todos BEGIN
+    INSERT INTO todos_fts (todos_fts, rowid, task) VALUES ('delete', OLD.id, OLD.task);
+END;
+CREATE TRIGGER todos_fts_update AFTER UPDATE OF task ON todos BEGIN
+    INSERT INTO todos_fts (todos_fts, rowid, task) VALUES ('delete', OLD.id, OLD.task);
+    INSERT INTO todos_fts (rowid, task) VALUES (NEW.id, NEW.task);
+END;
@@ -0,0 +1,2 @@
+ALTER TABLE todos ADD COLUMN deleted_at TIMESTAMP NULL DEFAULT NULL;
+CREATE INDEX todos_deleted_at ON todos (deleted_at);
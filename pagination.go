@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	QueryPage     = "page"
+	QueryPageSize = "pagesize"
+	HeaderLink    = "Link"
+)
+
+// Calculate the number of pages needed to hold total rows
+func TotalPages(total int, pagesize int) int {
+	if total <= 0 || pagesize <= 0 {
+		return 0
+	}
+	return (total + pagesize - 1) / pagesize
+}
+
+// Build RFC 5988 Link header value with first, prev, next and last relations.
+// All the other query parameters of the base URL are kept as they are.
+// https://datatracker.ietf.org/doc/html/rfc5988
+func PaginationLinks(base *url.URL, page int, pagesize int, totalPages int) string {
+	link := func(p int, rel string) string {
+		u := *base
+		q := u.Query()
+		q.Set(QueryPage, strconv.Itoa(p))
+		q.Set(QueryPageSize, strconv.Itoa(pagesize))
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf("<%s>; rel=\"%s\"", u.String(), rel)
+	}
+
+	// Always keep first and last so that clients can jump even on an empty result
+	lastPage := totalPages
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	links := []string{link(1, "first")}
+	if 1 < page {
+		prev := page - 1
+		if lastPage < prev {
+			prev = lastPage
+		}
+		links = append(links, link(prev, "prev"))
+	}
+	if page < totalPages {
+		links = append(links, link(page+1, "next"))
+	}
+	links = append(links, link(lastPage, "last"))
+
+	return strings.Join(links, ", ")
+}
+
+// Build RFC 5988 Link header value for keyset pagination.
+// Only first and next can be expressed since the cursor goes one way.
+func CursorLinks(base *url.URL, nextCursor string, pagesize int) string {
+	link := func(cursor string, rel string) string {
+		u := *base
+		q := u.Query()
+		q.Del(QueryPage)
+		q.Set(QueryCursor, cursor)
+		q.Set(QueryPageSize, strconv.Itoa(pagesize))
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf("<%s>; rel=\"%s\"", u.String(), rel)
+	}
+
+	return strings.Join([]string{link("", "first"), link(nextCursor, "next")}, ", ")
+}
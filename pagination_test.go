@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+)
+
+func TestPagination(t *testing.T) {
+	t.Parallel()
+
+	t.Run("TotalPages", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, 0, TotalPages(0, 10))
+		assert.Equal(t, 1, TotalPages(10, 10))
+		assert.Equal(t, 2, TotalPages(11, 10))
+		assert.Equal(t, 0, TotalPages(11, 0))
+	})
+
+	t.Run("PaginationLinks", func(t *testing.T) {
+		t.Parallel()
+
+		base, err := url.Parse("http://example.com/todos?status=true&page=2&pagesize=10")
+		assert.Nil(t, err)
+
+		links := PaginationLinks(base, 2, 10, 3)
+		assert.Equal(t,
+			"<http://example.com/todos?page=1&pagesize=10&status=true>; rel=\"first\", "+
+				"<http://example.com/todos?page=1&pagesize=10&status=true>; rel=\"prev\", "+
+				"<http://example.com/todos?page=3&pagesize=10&status=true>; rel=\"next\", "+
+				"<http://example.com/todos?page=3&pagesize=10&status=true>; rel=\"last\"",
+			links)
+
+		// No prev and next on a single page
+		links = PaginationLinks(base, 1, 10, 1)
+		assert.NotContains(t, links, "rel=\"prev\"")
+		assert.NotContains(t, links, "rel=\"next\"")
+	})
+
+	t.Run("CursorLinks", func(t *testing.T) {
+		t.Parallel()
+
+		base, err := url.Parse("http://example.com/todos?status=true&cursor=abc")
+		assert.Nil(t, err)
+
+		links := CursorLinks(base, "def", 10)
+		assert.Equal(t,
+			"<http://example.com/todos?cursor=&pagesize=10&status=true>; rel=\"first\", "+
+				"<http://example.com/todos?cursor=def&pagesize=10&status=true>; rel=\"next\"",
+			links)
+	})
+}
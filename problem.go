@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/glassonion1/logz"
+	"github.com/glassonion1/logz/middleware"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/xerrors"
+	"net/http"
+	"strings"
+)
+
+const (
+	// RFC 7807 Problem Details for HTTP APIs
+	// https://datatracker.ietf.org/doc/html/rfc7807
+	MIMEApplicationProblemJSON = "application/problem+json"
+
+	// The problem has no further semantics than the status code
+	ProblemTypeBlank = "about:blank"
+
+	codeInternalError = "internal_error"
+)
+
+type (
+	Problem struct {
+		Type     string `json:"type"`
+		Title    string `json:"title"`
+		Status   int    `json:"status"`
+		Detail   string `json:"detail,omitempty"`
+		Instance string `json:"instance,omitempty"`
+		// Extension members
+		Code    string        `json:"code"`
+		TraceID string        `json:"trace_id,omitempty"`
+		Errors  []*FieldError `json:"errors,omitempty"`
+	}
+)
+
+// Build the problem of the error. Only DomainError and echo.HTTPError tell the
+// client what went wrong, the other errors are reported as an internal error.
+func NewProblem(err error) *Problem {
+	status, code, detail := http.StatusInternalServerError, codeInternalError, ""
+	var fields []*FieldError
+
+	var domainErr *DomainError
+	var httpErr *echo.HTTPError
+	switch {
+	case xerrors.As(err, &domainErr):
+		status, code, detail = domainErr.Kind.Status(), domainErr.Code, domainErr.Detail
+		fields = domainErr.Fields
+	case xerrors.As(err, &httpErr):
+		// Errors of the router and the middlewares
+		status = httpErr.Code
+		code = statusCode(status)
+		if message, ok := httpErr.Message.(string); ok {
+			detail = message
+		}
+	}
+
+	return &Problem{
+		Type:   ProblemTypeBlank,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+		Errors: fields,
+	}
+}
+
+// Status code of the error as NewProblem maps it, 200 for nil
+func ErrorStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	return NewProblem(err).Status
+}
+
+// Echo HTTPErrorHandler rendering application/problem+json.
+// The whole error chain is logged, the client only sees the problem.
+// https://echo.labstack.com/guide/error-handling/
+func ProblemErrorHandler(err error, c echo.Context) {
+	ctx := c.Request().Context()
+
+	problem := NewProblem(err)
+	problem.Instance = c.Request().URL.Path
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		problem.TraceID = spanContext.TraceID().String()
+	}
+
+	if problem.Status < http.StatusInternalServerError {
+		logz.Warningf(ctx, "%s %s : %+v", c.Request().Method, problem.Instance, err)
+	} else {
+		logz.Errorf(ctx, "%s %s : %+v", c.Request().Method, problem.Instance, err)
+	}
+
+	// The handler has already started writing the response
+	if c.Response().Committed {
+		return
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(problem.Status)
+	} else {
+		err = renderProblem(c, problem)
+	}
+	if err != nil {
+		logz.Errorf(ctx, "%+v", xerrors.Errorf("ProblemErrorHandler : %+w", err))
+	}
+}
+
+func renderProblem(c echo.Context, problem *Problem) error {
+	body, err := json.Marshal(problem)
+	if err != nil {
+		return xerrors.Errorf("renderProblem : %+w", err)
+	}
+	return c.Blob(problem.Status, MIMEApplicationProblemJSON, body)
+}
+
+// snake_case of the status text such as method_not_allowed
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return fmt.Sprintf("http_%d", status)
+	}
+	if status == http.StatusInternalServerError {
+		return codeInternalError
+	}
+	return strings.ToLower(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
+
+// Start a span for every request so that its logs and problems share the trace id.
+// Errors are handled inside the span to log the access with the final status code.
+func TraceMiddleware(label string) echo.MiddlewareFunc {
+	wrapped := echo.WrapMiddleware(middleware.NetHTTP(label))
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return wrapped(func(c echo.Context) error {
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			return nil
+		})
+	}
+}
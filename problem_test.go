@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProblem(t *testing.T) {
+	t.Parallel()
+
+	t.Run("NewProblem", func(t *testing.T) {
+		t.Parallel()
+
+		// Domain error
+		problem := NewProblem(xerrors.Errorf("Update : %+w", ErrPreconditionFailed.Wrap(ErrVersionConflict)))
+		assert.Equal(t, http.StatusPreconditionFailed, problem.Status)
+		assert.Equal(t, ErrPreconditionFailed.Code, problem.Code)
+		assert.Equal(t, ProblemTypeBlank, problem.Type)
+		assert.Equal(t, "Precondition Failed", problem.Title)
+
+		// Router error
+		problem = NewProblem(echo.ErrMethodNotAllowed)
+		assert.Equal(t, http.StatusMethodNotAllowed, problem.Status)
+		assert.Equal(t, "method_not_allowed", problem.Code)
+
+		// Anything else never leaks
+		problem = NewProblem(xerrors.New("dial tcp 10.0.0.1:3306: connection refused"))
+		assert.Equal(t, http.StatusInternalServerError, problem.Status)
+		assert.Equal(t, "internal_error", problem.Code)
+		assert.Empty(t, problem.Detail)
+
+		assert.Equal(t, http.StatusOK, ErrorStatus(nil))
+	})
+
+	t.Run("ProblemErrorHandler", func(t *testing.T) {
+		t.Parallel()
+
+		e := echo.New()
+		e.HTTPErrorHandler = ProblemErrorHandler
+		e.GET("/todos/:id", func(c echo.Context) error {
+			return xerrors.Errorf("Get todo : %+w", ErrTodoNotFound)
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/todos/1", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+		var problem = &Problem{}
+		err := json.Unmarshal(rec.Body.Bytes(), &problem)
+		assert.Nil(t, err)
+		assert.Equal(t, ErrTodoNotFound.Code, problem.Code)
+		assert.Equal(t, ErrTodoNotFound.Detail, problem.Detail)
+		assert.Equal(t, "/todos/1", problem.Instance)
+
+		// Unknown route
+		req = httptest.NewRequest(http.MethodGet, "/unknown/path", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	})
+}
@@ -3,26 +3,65 @@ package main
 import (
 	"cloud.google.com/go/storage"
 	"context"
+	"golang.org/x/xerrors"
 )
 
 type (
 	Repository interface {
 		CloudSQL() CloudSQL
 		GCS() GCS
+		WithTx(ctx context.Context, fn func(tx Repository) error, opts ...TxOption) error
+		Close() error
 	}
 	repository struct {
 		cloudSQL CloudSQL
 		gcs      GCS
 	}
+
+	// Repository bound to a transaction, passed to the fn of WithTx
+	repositoryTx struct {
+		*repository
+	}
+
+	RepositoryOption func(*repository)
 )
 
-func NewRepository(ctx context.Context, client *storage.Client) Repository {
-	return &repository{
-		cloudSQL: NewCloudSQL(ctx),
-		gcs:      NewGCS(ctx, client),
+// Use the given CloudSQL instead of connecting to the database
+func WithCloudSQL(cloudSQL CloudSQL) RepositoryOption {
+	return func(r *repository) {
+		r.cloudSQL = cloudSQL
+	}
+}
+
+// Use the given GCS instead of creating a storage client
+func WithGCS(gcs GCS) RepositoryOption {
+	return func(r *repository) {
+		r.gcs = gcs
 	}
 }
 
+// Aggregate of the data stores. The components which are not given by the options
+// are built, client is passed to NewGCS as it is.
+func NewRepository(ctx context.Context, client *storage.Client, opts ...RepositoryOption) (Repository, error) {
+	r := &repository{}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.cloudSQL == nil {
+		cloudSQL, err := NewCloudSQL(ctx)
+		if err != nil {
+			return nil, xerrors.Errorf("NewRepository : %+w", err)
+		}
+		r.cloudSQL = cloudSQL
+	}
+	if r.gcs == nil {
+		r.gcs = NewGCS(ctx, client)
+	}
+
+	return r, nil
+}
+
 func (r *repository) CloudSQL() CloudSQL {
 	return r.cloudSQL
 }
@@ -30,3 +69,29 @@ func (r *repository) CloudSQL() CloudSQL {
 func (r *repository) GCS() GCS {
 	return r.gcs
 }
+
+// Unit of work over CloudSQL. The repository passed to fn queries in the transaction,
+// calling WithTx on it makes a savepoint.
+func (r *repository) WithTx(ctx context.Context, fn func(tx Repository) error, opts ...TxOption) error {
+	return r.cloudSQL.WithTx(ctx, func(tx CloudSQL) error {
+		return fn(&repositoryTx{&repository{cloudSQL: tx, gcs: r.gcs}})
+	}, opts...)
+}
+
+// Closing the shared pool and storage client is refused like cloudSQLTx does
+func (r *repositoryTx) Close() error {
+	return xerrors.Errorf("Close : %+w", ErrCloseInTx)
+}
+
+// Close the database pool and the storage client
+func (r *repository) Close() error {
+	sqlErr := r.cloudSQL.Close()
+	gcsErr := r.gcs.Close()
+	if sqlErr != nil {
+		return xerrors.Errorf("Close : %+w", sqlErr)
+	}
+	if gcsErr != nil {
+		return xerrors.Errorf("Close : %+w", gcsErr)
+	}
+	return nil
+}
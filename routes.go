@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/labstack/echo/v4"
+	"time"
+)
+
+var (
+	// When /v1 replaced the paths at the root
+	legacyRoutesDeprecation = time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+)
+
+// Todo resource of /v1. A v2 registers its own controller on e.Group("/v2")
+// next to it, so that both versions are served side by side.
+func registerTodoRoutesV1(g *echo.Group, todoController TodoController) {
+	g.GET("/todos", todoController.List)
+	g.POST("/todos", todoController.Create)
+	g.GET("/todos/search", todoController.Search)
+	g.GET("/todos/trash", todoController.ListTrash)
+	g.GET("/todos/by-slug/:slug", todoController.GetBySlug)
+	g.PUT("/todos/by-slug/:slug", todoController.UpdateBySlug)
+	g.DELETE("/todos/by-slug/:slug", todoController.DeleteBySlug)
+	// :batchCreate, :batchUpdate and :batchDelete
+	g.POST("/todos:action", todoController.Batch)
+	g.GET("/todos/:id", todoController.Get)
+	g.PUT("/todos/:id", todoController.UpdateByID)
+	g.PATCH("/todos/:id", todoController.Patch)
+	g.DELETE("/todos/:id", todoController.Delete)
+	g.POST("/todos/:id/restore", todoController.Restore)
+}
+
+// Paths before /v1, deprecated in favor of it. The middleware is given to every route
+// rather than to a group at the root, which would catch every unknown path.
+func registerLegacyTodoRoutes(e *echo.Echo, todoController TodoController, deprecated echo.MiddlewareFunc) {
+	e.GET("/todos", todoController.List, deprecated)
+	e.GET("/todos/search", todoController.Search, deprecated)
+	e.GET("/todos/trash", todoController.ListTrash, deprecated)
+	e.POST("/todos/:id/restore", todoController.Restore, deprecated)
+	e.PATCH("/todos/:id", todoController.Patch, deprecated)
+	e.GET("/todos/by-slug/:slug", todoController.GetBySlug, deprecated)
+	e.PUT("/todos/by-slug/:slug", todoController.UpdateBySlug, deprecated)
+	e.DELETE("/todos/by-slug/:slug", todoController.DeleteBySlug, deprecated)
+	e.POST("/todos:action", todoController.Batch, deprecated)
+	e.GET("/:id", todoController.Get, deprecated)
+	e.POST("/", todoController.Create, deprecated)
+	e.DELETE("/:id", todoController.Delete, deprecated)
+	e.PUT("/", todoController.Update, deprecated)
+}
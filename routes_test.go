@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	router := newTestRouter(t, ctx,
+		WithRepository(&fakeRepository{}),
+		WithTodoService(&fakeTodoService{todos: map[int64]*Todo{1: {ID: 1, Task: "fake", Version: 1}}}),
+	)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("v1", func(t *testing.T) {
+		t.Parallel()
+
+		rec := get("/v1/todos/1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "\"task\":\"fake\"")
+		assert.Empty(t, rec.Header().Get(HeaderDeprecation))
+		assert.Empty(t, rec.Header().Get(HeaderSunset))
+	})
+
+	t.Run("Legacy paths are deprecated", func(t *testing.T) {
+		t.Parallel()
+
+		rec := get("/1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "\"task\":\"fake\"")
+		assert.Equal(t, "@1792195200", rec.Header().Get(HeaderDeprecation))
+		assert.Equal(t, GetApplicationConfig(ctx).LegacyRoutesSunset.Format(http.TimeFormat), rec.Header().Get(HeaderSunset))
+		assert.Equal(t, "</v1/todos>; rel=\"successor-version\"", rec.Header().Get(HeaderLink))
+	})
+
+	t.Run("Probes are not todos", func(t *testing.T) {
+		t.Parallel()
+
+		rec := get("/healthz")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get(HeaderDeprecation))
+	})
+}
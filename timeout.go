@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"time"
+)
+
+const (
+	// Time left to write the 503 of a request which ran to its deadline
+	writeTimeoutMargin = 5 * time.Second
+)
+
+var (
+	ErrRequestTimeout = NewDomainError(KindUnavailable, "request_timeout", "request timed out")
+)
+
+type (
+	TimeoutConfig struct {
+		// Deadline of the requests of the routes which are not in Routes
+		Timeout time.Duration
+		// Deadlines of the routes such as "POST /todos:action" which may take longer
+		Routes map[string]time.Duration
+	}
+)
+
+// Timeouts of the routes from TIMEOUT and REQUEST_TIMEOUT
+func NewTimeoutConfig(ctx context.Context, longRoutes ...string) TimeoutConfig {
+	config := GetApplicationConfig(ctx)
+	timeoutConfig := TimeoutConfig{
+		Timeout: time.Duration(config.RequestTimeout) * time.Second,
+		Routes:  map[string]time.Duration{},
+	}
+	for _, route := range longRoutes {
+		timeoutConfig.Routes[route] = time.Duration(config.TimeOut) * time.Second
+	}
+	return timeoutConfig
+}
+
+// Bound the request context so that the DB and GCS calls of the handler give up
+// at the deadline. The handler is not abandoned, a handler ignoring the context
+// runs on until the write timeout of the server.
+func TimeoutMiddleware(config TimeoutConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			timeout := config.Timeout
+			if routeTimeout, ok := config.Routes[c.Request().Method+" "+c.Path()]; ok {
+				timeout = routeTimeout
+			}
+			if timeout <= 0 {
+				return next(c)
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+			defer cancel()
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			err := next(c)
+			if err != nil && ctx.Err() == context.DeadlineExceeded && !c.Response().Committed {
+				return ErrRequestTimeout.Wrap(err)
+			}
+			return err
+		}
+	}
+}
+
+// Read and write timeouts of the server from TIMEOUT. Writes get a margin
+// so that the 503 of a request which ran to its deadline is still sent.
+func applyServerTimeouts(ctx context.Context, server *http.Server) {
+	config := GetApplicationConfig(ctx)
+	server.ReadTimeout = time.Duration(config.TimeOut) * time.Second
+	server.WriteTimeout = time.Duration(config.TimeOut)*time.Second + writeTimeoutMargin
+	server.IdleTimeout = time.Duration(config.IdleTimeout) * time.Second
+}
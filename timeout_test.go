@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	t.Parallel()
+
+	// Waits for the deadline like a DB call would
+	slow := func(c echo.Context) error {
+		select {
+		case <-c.Request().Context().Done():
+			return xerrors.Errorf("slow : %+w", c.Request().Context().Err())
+		case <-time.After(200 * time.Millisecond):
+			return c.NoContent(http.StatusNoContent)
+		}
+	}
+
+	e := echo.New()
+	e.HTTPErrorHandler = ProblemErrorHandler
+	e.Use(TimeoutMiddleware(TimeoutConfig{
+		Timeout: 20 * time.Millisecond,
+		Routes:  map[string]time.Duration{"POST /slow": time.Second},
+	}))
+	e.GET("/slow", slow)
+	e.POST("/slow", slow)
+	e.GET("/fail", func(c echo.Context) error {
+		return ErrTodoNotFound
+	})
+
+	serve := func(method string, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Expires", func(t *testing.T) {
+		t.Parallel()
+
+		rec := serve(http.MethodGet, "/slow")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+		problem := &Problem{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), problem))
+		assert.Equal(t, ErrRequestTimeout.Code, problem.Code)
+	})
+
+	t.Run("Route override", func(t *testing.T) {
+		t.Parallel()
+
+		rec := serve(http.MethodPost, "/slow")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+
+	t.Run("Errors before the deadline", func(t *testing.T) {
+		t.Parallel()
+
+		rec := serve(http.MethodGet, "/fail")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestTimeoutConfig(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	config := GetApplicationConfig(ctx)
+
+	timeoutConfig := NewTimeoutConfig(ctx, "POST /todos:action")
+	assert.Equal(t, time.Duration(config.RequestTimeout)*time.Second, timeoutConfig.Timeout)
+	assert.Equal(t, time.Duration(config.TimeOut)*time.Second, timeoutConfig.Routes["POST /todos:action"])
+
+	server := &http.Server{}
+	applyServerTimeouts(ctx, server)
+	assert.Equal(t, time.Duration(config.TimeOut)*time.Second, server.ReadTimeout)
+	assert.Equal(t, time.Duration(config.TimeOut)*time.Second+writeTimeoutMargin, server.WriteTimeout)
+	assert.Equal(t, time.Duration(config.IdleTimeout)*time.Second, server.IdleTimeout)
+}
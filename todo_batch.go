@@ -0,0 +1,29 @@
+package main
+
+const (
+	// All items succeed or none
+	BatchModeAtomic = "atomic"
+	// Each item succeeds or fails on its own
+	BatchModePartial = "partial"
+)
+
+var (
+	ErrInvalidBatch    = NewDomainError(KindInvalid, "invalid_batch", "invalid batch")
+	ErrBatchRolledBack = NewDomainError(KindFailedDependency, "batch_rolled_back", "rolled back by another item")
+)
+
+type (
+	BatchDeleteItem struct {
+		ID int64 `json:"id" validate:"required,min=1"`
+		// The version which the client has read, zero to delete regardless
+		Version int64 `json:"version"`
+	}
+
+	// Outcome of each item in the order of the request
+	BatchResult struct {
+		Index int
+		ID    int64
+		Todo  *Todo
+		Err   error
+	}
+)
@@ -6,6 +6,7 @@ import (
 	"github.com/glassonion1/logz"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/xerrors"
+	"io/ioutil"
 	"net/http"
 	"strconv"
 	"time"
@@ -14,69 +15,265 @@ import (
 type (
 	TodoController interface {
 		List(c echo.Context) error
+		Search(c echo.Context) error
 		Get(c echo.Context) error
+		GetBySlug(c echo.Context) error
 		Create(c echo.Context) error
 		Delete(c echo.Context) error
+		DeleteBySlug(c echo.Context) error
 		Update(c echo.Context) error
+		UpdateByID(c echo.Context) error
+		UpdateBySlug(c echo.Context) error
+		Patch(c echo.Context) error
+		ListTrash(c echo.Context) error
+		Restore(c echo.Context) error
+		Batch(c echo.Context) error
 	}
 	todoController struct {
 		todoService TodoService
+		config      *applicationConfig
+	}
+
+	// Paginated search result envelope
+	TodoSearchList struct {
+		Items      []*TodoSearchResult `json:"items"`
+		Total      int                 `json:"total"`
+		Page       int                 `json:"page"`
+		PageSize   int                 `json:"pagesize"`
+		TotalPages int                 `json:"total_pages"`
+	}
+
+	// Body of POST /todos:batchCreate and /todos:batchUpdate
+	TodoBatchRequest struct {
+		Mode  string  `json:"mode" validate:"omitempty,oneof=atomic partial"`
+		Items []*Todo `json:"items" validate:"dive,required"`
+	}
+
+	// Body of POST /todos:batchDelete
+	TodoBatchDeleteRequest struct {
+		Mode  string             `json:"mode" validate:"omitempty,oneof=atomic partial"`
+		Items []*BatchDeleteItem `json:"items" validate:"dive,required"`
+	}
+
+	// Per item status report
+	TodoBatchResponse struct {
+		Mode      string             `json:"mode"`
+		Succeeded int                `json:"succeeded"`
+		Failed    int                `json:"failed"`
+		Results   []*BatchItemResult `json:"results"`
+	}
+
+	BatchItemResult struct {
+		Index  int    `json:"index"`
+		Status int    `json:"status"`
+		ID     int64  `json:"id,omitempty"`
+		Todo   *Todo  `json:"todo,omitempty"`
+		Code   string `json:"code,omitempty"`
+		Error  string `json:"error,omitempty"`
+	}
+
+	// Keyset paginated list envelope
+	TodoCursorList struct {
+		Items      []*Todo `json:"items"`
+		PageSize   int     `json:"pagesize"`
+		NextCursor string  `json:"next_cursor,omitempty"`
+	}
+
+	// Paginated list envelope
+	TodoList struct {
+		Items      []*Todo `json:"items"`
+		Total      int     `json:"total"`
+		Page       int     `json:"page"`
+		PageSize   int     `json:"pagesize"`
+		TotalPages int     `json:"total_pages"`
 	}
 )
 
-func NewTodoController(ctx context.Context) TodoController {
+func NewTodoController(ctx context.Context, todoService TodoService) TodoController {
 	return &todoController{
-		todoService: NewTodoService(ctx),
+		todoService: todoService,
+		config:      GetApplicationConfig(ctx),
 	}
 }
 
 func (t *todoController) List(c echo.Context) error {
-	status, err := strconv.ParseBool(c.QueryParam("status"))
+	filter, err := ParseTodoFilter(c.QueryParams())
+	if err != nil {
+		return xerrors.Errorf("Invalid parameter : %+w", err)
+	}
+
+	orders, err := ParseSort(c.QueryParam(QuerySort))
 	if err != nil {
-		errx := xerrors.Errorf("Missing parameter : status : %+w", err)
-		logz.Errorf(c.Request().Context(), "%+v", errx)
-		return echo.NewHTTPError(http.StatusBadRequest, errx)
+		return xerrors.Errorf("Invalid parameter : %+w", err)
 	}
 
-	page, err := strconv.Atoi(c.QueryParam("page"))
+	pagesize, err := strconv.Atoi(c.QueryParam(QueryPageSize))
 	if err != nil {
-		errx := xerrors.Errorf("Missing parameter : page : %+w", err)
-		logz.Errorf(c.Request().Context(), "%+v", errx)
-		return echo.NewHTTPError(http.StatusBadRequest, errx)
+		return xerrors.Errorf("Missing parameter : pagesize : %+w", ErrInvalidParameter.Withf("%s must be an integer", QueryPageSize).Wrap(err))
+	}
+	if pagesize < 1 {
+		return xerrors.Errorf("Invalid parameter : pagesize %d : %+w", pagesize, ErrInvalidParameter.Withf("%s must be greater than 0", QueryPageSize))
 	}
 
-	pagesize, err := strconv.Atoi(c.QueryParam("pagesize"))
+	// Keyset pagination when cursor is given, even if it's empty
+	if _, ok := c.QueryParams()[QueryCursor]; ok {
+		// Keyset pagination relies on its own fixed order
+		if orders != nil {
+			return xerrors.Errorf("Invalid parameter : %+w", ErrInvalidQuery.Withf("%s can not be used with %s", QuerySort, QueryCursor))
+		}
+		return t.listByCursor(c, filter, pagesize)
+	}
+
+	page, err := strconv.Atoi(c.QueryParam(QueryPage))
 	if err != nil {
-		errx := xerrors.Errorf("Missing parameter : pagesize : %+w", err)
-		logz.Errorf(c.Request().Context(), "%+v", errx)
-		return echo.NewHTTPError(http.StatusBadRequest, errx)
+		return xerrors.Errorf("Missing parameter : page : %+w", ErrInvalidParameter.Withf("%s must be an integer", QueryPage).Wrap(err))
+	}
+
+	// The service treats page 0 or less as the first page
+	if page < 1 {
+		page = 1
 	}
 
-	todos, rows, err := t.todoService.List(status, page, pagesize, "updated_at DESC")
+	todos, rows, err := t.todoService.List(c.Request().Context(), filter, page, pagesize, orders)
 	if err != nil {
-		errx := xerrors.Errorf("Fetch List : %+w", err)
-		logz.Errorf(c.Request().Context(), "%+v", errx)
-		return echo.NewHTTPError(http.StatusBadRequest, errx)
+		return xerrors.Errorf("Fetch List : %+w", err)
 	}
 
 	logz.Infof(c.Request().Context(), "fetched row: %+v", rows)
 
-	return c.JSON(http.StatusOK, todos)
+	// Always render an array, even when nothing matches
+	if todos == nil {
+		todos = []*Todo{}
+	}
+
+	totalPages := TotalPages(rows, pagesize)
+
+	// Pager links
+	base := *c.Request().URL
+	base.Scheme = c.Scheme()
+	base.Host = c.Request().Host
+	c.Response().Header().Add(HeaderLink, PaginationLinks(&base, page, pagesize, totalPages))
+
+	return c.JSON(http.StatusOK, &TodoList{
+		Items:      todos,
+		Total:      rows,
+		Page:       page,
+		PageSize:   pagesize,
+		TotalPages: totalPages,
+	})
+}
+
+func (t *todoController) listByCursor(c echo.Context, filter *TodoFilter, pagesize int) error {
+	todos, nextCursor, err := t.todoService.ListByCursor(c.Request().Context(), filter, c.QueryParam(QueryCursor), pagesize)
+	if err != nil {
+		return xerrors.Errorf("Fetch List : %+w", err)
+	}
+
+	// Always render an array, even when nothing matches
+	if todos == nil {
+		todos = []*Todo{}
+	}
+
+	// Pager link
+	if nextCursor != "" {
+		base := *c.Request().URL
+		base.Scheme = c.Scheme()
+		base.Host = c.Request().Host
+		c.Response().Header().Add(HeaderLink, CursorLinks(&base, nextCursor, pagesize))
+	}
+
+	return c.JSON(http.StatusOK, &TodoCursorList{
+		Items:      todos,
+		PageSize:   pagesize,
+		NextCursor: nextCursor,
+	})
+}
+
+func (t *todoController) Search(c echo.Context) error {
+	query := c.QueryParam(QuerySearch)
+	if query == "" {
+		return xerrors.Errorf("Missing parameter : %+w", ErrInvalidParameter.Withf("%s is required", QuerySearch))
+	}
+
+	page, err := strconv.Atoi(c.QueryParam(QueryPage))
+	if err != nil {
+		return xerrors.Errorf("Missing parameter : page : %+w", ErrInvalidParameter.Withf("%s must be an integer", QueryPage).Wrap(err))
+	}
+
+	pagesize, err := strconv.Atoi(c.QueryParam(QueryPageSize))
+	if err != nil {
+		return xerrors.Errorf("Missing parameter : pagesize : %+w", ErrInvalidParameter.Withf("%s must be an integer", QueryPageSize).Wrap(err))
+	}
+	if pagesize < 1 {
+		return xerrors.Errorf("Invalid parameter : pagesize %d : %+w", pagesize, ErrInvalidParameter.Withf("%s must be greater than 0", QueryPageSize))
+	}
+
+	// The service treats page 0 or less as the first page
+	if page < 1 {
+		page = 1
+	}
+
+	results, rows, err := t.todoService.Search(c.Request().Context(), query, &SearchOptions{
+		Mode:     c.QueryParam(QuerySearchMode),
+		Page:     page,
+		PageSize: pagesize,
+	})
+	if err != nil {
+		return xerrors.Errorf("Search : %+w", err)
+	}
+
+	// Always render an array, even when nothing matches
+	if results == nil {
+		results = []*TodoSearchResult{}
+	}
+
+	totalPages := TotalPages(rows, pagesize)
+
+	// Pager links
+	base := *c.Request().URL
+	base.Scheme = c.Scheme()
+	base.Host = c.Request().Host
+	c.Response().Header().Add(HeaderLink, PaginationLinks(&base, page, pagesize, totalPages))
+
+	return c.JSON(http.StatusOK, &TodoSearchList{
+		Items:      results,
+		Total:      rows,
+		Page:       page,
+		PageSize:   pagesize,
+		TotalPages: totalPages,
+	})
 }
 
 func (t *todoController) Get(c echo.Context) error {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		errx := xerrors.Errorf("Missing parameter : id : %+w", err)
-		logz.Errorf(c.Request().Context(), "%+v", errx)
-		return echo.NewHTTPError(http.StatusBadRequest, errx)
+		return xerrors.Errorf("Missing parameter : id : %+w", ErrInvalidParameter.Withf("id must be an integer").Wrap(err))
+	}
+
+	todo, err := t.todoService.Get(c.Request().Context(), id)
+	if err != nil {
+		return xerrors.Errorf("Get todo : id %d : %+w", id, err)
 	}
 
-	todo, err := t.todoService.Get(id)
+	return t.render(c, todo)
+}
+
+func (t *todoController) GetBySlug(c echo.Context) error {
+	todo, err := t.todoService.GetBySlug(c.Request().Context(), c.Param("slug"))
 	if err != nil {
-		errx := xerrors.Errorf("Get todo: id %+w, %+w", id, err)
-		logz.Errorf(c.Request().Context(), "%+v", errx)
-		return echo.NewHTTPError(http.StatusOK, errx)
+		return xerrors.Errorf("Get todo : slug %s : %+w", c.Param("slug"), err)
+	}
+
+	return t.render(c, todo)
+}
+
+// Render the todo honoring If-None-Match
+func (t *todoController) render(c echo.Context, todo *Todo) error {
+	// Conditional GET
+	etag := TodoETag(todo)
+	c.Response().Header().Set(HeaderETag, etag)
+	if ifNoneMatch := c.Request().Header.Get(HeaderIfNoneMatch); ifNoneMatch != "" && MatchETagWeak(ifNoneMatch, etag) {
+		return c.NoContent(http.StatusNotModified)
 	}
 
 	return c.JSON(http.StatusOK, todo)
@@ -85,13 +282,14 @@ func (t *todoController) Get(c echo.Context) error {
 func (t *todoController) Create(c echo.Context) error {
 	paramObj := &Todo{}
 	if err := c.Bind(paramObj); err != nil {
-		errx := xerrors.Errorf("Failed to bind parameter into todo object %+w", err)
-		logz.Errorf(c.Request().Context(), "%+v", errx)
-		return echo.NewHTTPError(http.StatusBadRequest, errx)
+		return xerrors.Errorf("Failed to bind parameter into todo object : %+w", ErrInvalidBody.Wrap(err))
+	}
+	if err := c.Validate(paramObj); err != nil {
+		return xerrors.Errorf("Create todo : %+w", err)
 	}
 
 	// Create
-	todo, err := t.todoService.Create(&Todo{
+	todo, err := t.todoService.Create(c.Request().Context(), &Todo{
 		Slug:      paramObj.Slug,
 		Task:      paramObj.Task,
 		Status:    paramObj.Status,
@@ -100,29 +298,59 @@ func (t *todoController) Create(c echo.Context) error {
 	})
 
 	if err != nil {
-		errx := xerrors.Errorf("Create todo : %+w", err)
-		logz.Errorf(c.Request().Context(), "%+v", errx)
-		return echo.NewHTTPError(http.StatusBadRequest, errx)
+		return xerrors.Errorf("Create todo : %+w", err)
 	}
 
+	c.Response().Header().Set(HeaderETag, TodoETag(todo))
 	return c.JSON(http.StatusOK, todo)
 }
 
 func (t *todoController) Delete(c echo.Context) error {
 	ID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		errx := xerrors.Errorf("Missing parameter : id : %+w", err)
-		logz.Errorf(c.Request().Context(), "%+v", errx)
-		return echo.NewHTTPError(http.StatusBadRequest, errx)
+		return xerrors.Errorf("Missing parameter : id : %+w", ErrInvalidParameter.Withf("id must be an integer").Wrap(err))
+	}
+
+	return t.delete(c, ID)
+}
+
+func (t *todoController) DeleteBySlug(c echo.Context) error {
+	orgTodo, err := t.todoService.GetBySlug(c.Request().Context(), c.Param("slug"))
+	if err != nil {
+		return xerrors.Errorf("Delete todo : slug %s : %+w", c.Param("slug"), err)
+	}
+
+	return t.delete(c, orgTodo.ID)
+}
+
+// Delete honoring If-Match
+func (t *todoController) delete(c echo.Context, ID int64) error {
+	// Conditional Delete
+	ifMatch := c.Request().Header.Get(HeaderIfMatch)
+	if ifMatch != "" {
+		orgTodo, err := t.todoService.Get(c.Request().Context(), ID)
+		if err != nil {
+			return xerrors.Errorf("Delete todo : %+w", err)
+		}
+
+		if !MatchETag(ifMatch, TodoETag(orgTodo)) {
+			return xerrors.Errorf("Delete todo : %+w", ErrPreconditionFailed.Withf("%s does not match %s", HeaderIfMatch, TodoETag(orgTodo)))
+		}
+
+		rowsAffected, err := t.todoService.DeleteVersion(c.Request().Context(), ID, orgTodo.Version)
+		if err != nil {
+			return xerrors.Errorf("Delete todo : %+w", preconditionFailed(err))
+		}
+
+		return c.String(http.StatusOK,
+			fmt.Sprintf("{ \"RowsAffected\": %d }", rowsAffected))
 	}
 
 	// Delete
-	rowsAffected, err := t.todoService.Delete(ID)
+	rowsAffected, err := t.todoService.Delete(c.Request().Context(), ID)
 
 	if err != nil {
-		errx := xerrors.Errorf("Delete todo : %+w", err)
-		logz.Errorf(c.Request().Context(), "%+v", errx)
-		return echo.NewHTTPError(http.StatusBadRequest, errx)
+		return xerrors.Errorf("Delete todo : %+w", err)
 	}
 
 	return c.String(http.StatusOK,
@@ -132,33 +360,318 @@ func (t *todoController) Delete(c echo.Context) error {
 func (t *todoController) Update(c echo.Context) error {
 	paramObj := &Todo{}
 	if err := c.Bind(paramObj); err != nil {
-		errx := xerrors.Errorf("Failed to bind parameter into todo object %+w", err)
-		logz.Errorf(c.Request().Context(), "%+v", errx)
-		return echo.NewHTTPError(http.StatusBadRequest, errx)
+		return xerrors.Errorf("Failed to bind parameter into todo object : %+w", ErrInvalidBody.Wrap(err))
+	}
+	if err := c.Validate(paramObj); err != nil {
+		return xerrors.Errorf("Update todo : %+w", err)
 	}
 
-	orgTodo, err := t.todoService.Get(paramObj.ID)
+	orgTodo, err := t.todoService.Get(c.Request().Context(), paramObj.ID)
 	if err != nil {
-		errx := xerrors.Errorf("ID %+w does not exist. %+w", paramObj.ID, err)
-		logz.Errorf(c.Request().Context(), "%+v", errx)
-		return echo.NewHTTPError(http.StatusBadRequest, errx)
+		return xerrors.Errorf("Update todo : id %d : %+w", paramObj.ID, err)
+	}
+
+	return t.update(c, paramObj, orgTodo)
+}
+
+// PUT /v1/todos/:id, the id of the path wins over the one of the body
+func (t *todoController) UpdateByID(c echo.Context) error {
+	ID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return xerrors.Errorf("Missing parameter : id : %+w", ErrInvalidParameter.Withf("id must be an integer").Wrap(err))
+	}
+
+	paramObj := &Todo{}
+	if err := c.Bind(paramObj); err != nil {
+		return xerrors.Errorf("Failed to bind parameter into todo object : %+w", ErrInvalidBody.Wrap(err))
+	}
+	if err := c.Validate(paramObj); err != nil {
+		return xerrors.Errorf("Update todo : %+w", err)
+	}
+
+	orgTodo, err := t.todoService.Get(c.Request().Context(), ID)
+	if err != nil {
+		return xerrors.Errorf("Update todo : id %d : %+w", ID, err)
+	}
+
+	return t.update(c, paramObj, orgTodo)
+}
+
+func (t *todoController) UpdateBySlug(c echo.Context) error {
+	paramObj := &Todo{}
+	if err := c.Bind(paramObj); err != nil {
+		return xerrors.Errorf("Failed to bind parameter into todo object : %+w", ErrInvalidBody.Wrap(err))
+	}
+	if err := c.Validate(paramObj); err != nil {
+		return xerrors.Errorf("Update todo : %+w", err)
+	}
+
+	orgTodo, err := t.todoService.GetBySlug(c.Request().Context(), c.Param("slug"))
+	if err != nil {
+		return xerrors.Errorf("Update todo : slug %s : %+w", c.Param("slug"), err)
+	}
+
+	return t.update(c, paramObj, orgTodo)
+}
+
+// Replace orgTodo with paramObj honoring If-Match.
+// The slug is a stable identifier, the body may only repeat it.
+func (t *todoController) update(c echo.Context, paramObj *Todo, orgTodo *Todo) error {
+	if err := checkSlugUnchanged(paramObj, orgTodo); err != nil {
+		return xerrors.Errorf("Update todo : %+w", err)
+	}
+
+	// Conditional Update
+	ifMatch := c.Request().Header.Get(HeaderIfMatch)
+	if ifMatch != "" && !MatchETag(ifMatch, TodoETag(orgTodo)) {
+		return xerrors.Errorf("Update todo : %+w", ErrPreconditionFailed.Withf("%s does not match %s", HeaderIfMatch, TodoETag(orgTodo)))
+	}
+
+	// The version the client has read, the current one when it's not given
+	version := orgTodo.Version
+	if ifMatch == "" && paramObj.Version != 0 {
+		version = paramObj.Version
 	}
 
 	// Update
-	todo, err := t.todoService.Update(&Todo{
+	todo, err := t.todoService.Update(c.Request().Context(), &Todo{
 		ID:        orgTodo.ID,
-		Slug:      paramObj.Slug,
+		Slug:      orgTodo.Slug,
 		Task:      paramObj.Task,
 		Status:    paramObj.Status,
 		UpdatedAt: time.Time.UTC(time.Now()),
 		CreatedAt: orgTodo.CreatedAt,
+		Version:   version,
+	})
+
+	if err != nil {
+		if ifMatch != "" {
+			err = preconditionFailed(err)
+		}
+		return xerrors.Errorf("Update todo : %+w", err)
+	}
+
+	c.Response().Header().Set(HeaderETag, TodoETag(todo))
+	return c.JSON(http.StatusOK, todo)
+}
+
+// Partial update with JSON Merge Patch or JSON Patch
+func (t *todoController) Patch(c echo.Context) error {
+	ID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return xerrors.Errorf("Missing parameter : id : %+w", ErrInvalidParameter.Withf("id must be an integer").Wrap(err))
+	}
+
+	body, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		return xerrors.Errorf("Failed to read patch document : %+w", ErrInvalidBody.Wrap(err))
+	}
+
+	orgTodo, err := t.todoService.Get(c.Request().Context(), ID)
+	if err != nil {
+		return xerrors.Errorf("Patch todo : id %d : %+w", ID, err)
+	}
+
+	// Conditional Patch
+	ifMatch := c.Request().Header.Get(HeaderIfMatch)
+	if ifMatch != "" && !MatchETag(ifMatch, TodoETag(orgTodo)) {
+		return xerrors.Errorf("Patch todo : %+w", ErrPreconditionFailed.Withf("%s does not match %s", HeaderIfMatch, TodoETag(orgTodo)))
+	}
+
+	patch, err := NewTodoPatch(orgTodo, c.Request().Header.Get(echo.HeaderContentType), body)
+	if err != nil {
+		return xerrors.Errorf("Patch todo : %+w", err)
+	}
+
+	todo, err := t.todoService.Patch(c.Request().Context(), ID, orgTodo.Version, patch)
+	if err != nil {
+		if ifMatch != "" {
+			err = preconditionFailed(err)
+		}
+		return xerrors.Errorf("Patch todo : %+w", err)
+	}
+
+	c.Response().Header().Set(HeaderETag, TodoETag(todo))
+	return c.JSON(http.StatusOK, todo)
+}
+
+func (t *todoController) ListTrash(c echo.Context) error {
+	page, err := strconv.Atoi(c.QueryParam(QueryPage))
+	if err != nil {
+		return xerrors.Errorf("Missing parameter : page : %+w", ErrInvalidParameter.Withf("%s must be an integer", QueryPage).Wrap(err))
+	}
+
+	pagesize, err := strconv.Atoi(c.QueryParam(QueryPageSize))
+	if err != nil {
+		return xerrors.Errorf("Missing parameter : pagesize : %+w", ErrInvalidParameter.Withf("%s must be an integer", QueryPageSize).Wrap(err))
+	}
+	if pagesize < 1 {
+		return xerrors.Errorf("Invalid parameter : pagesize %d : %+w", pagesize, ErrInvalidParameter.Withf("%s must be greater than 0", QueryPageSize))
+	}
+
+	// The service treats page 0 or less as the first page
+	if page < 1 {
+		page = 1
+	}
+
+	todos, rows, err := t.todoService.ListTrash(c.Request().Context(), page, pagesize)
+	if err != nil {
+		return xerrors.Errorf("Fetch Trash : %+w", err)
+	}
+
+	// Always render an array, even when nothing matches
+	if todos == nil {
+		todos = []*Todo{}
+	}
+
+	totalPages := TotalPages(rows, pagesize)
+
+	// Pager links
+	base := *c.Request().URL
+	base.Scheme = c.Scheme()
+	base.Host = c.Request().Host
+	c.Response().Header().Add(HeaderLink, PaginationLinks(&base, page, pagesize, totalPages))
+
+	return c.JSON(http.StatusOK, &TodoList{
+		Items:      todos,
+		Total:      rows,
+		Page:       page,
+		PageSize:   pagesize,
+		TotalPages: totalPages,
 	})
+}
+
+func (t *todoController) Restore(c echo.Context) error {
+	ID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return xerrors.Errorf("Missing parameter : id : %+w", ErrInvalidParameter.Withf("id must be an integer").Wrap(err))
+	}
 
+	todo, err := t.todoService.Restore(c.Request().Context(), ID)
 	if err != nil {
-		errx := xerrors.Errorf("Update todo : %+w", err)
-		logz.Errorf(c.Request().Context(), "%+v", errx)
-		return echo.NewHTTPError(http.StatusBadRequest, errx)
+		return xerrors.Errorf("Restore todo : %+w", err)
 	}
 
+	c.Response().Header().Set(HeaderETag, TodoETag(todo))
 	return c.JSON(http.StatusOK, todo)
 }
+
+// POST /todos:batchCreate, /todos:batchUpdate and /todos:batchDelete
+func (t *todoController) Batch(c echo.Context) error {
+	var (
+		mode    string
+		size    int
+		results []*BatchResult
+		err     error
+	)
+
+	switch c.Param("action") {
+	case ":batchCreate", ":batchUpdate":
+		paramObj := &TodoBatchRequest{}
+		if err := c.Bind(paramObj); err != nil {
+			return xerrors.Errorf("Failed to bind parameter into batch object : %+w", ErrInvalidBody.Wrap(err))
+		}
+		mode, size = paramObj.Mode, len(paramObj.Items)
+		if err := t.validateBatchSize(size); err != nil {
+			return xerrors.Errorf("Batch : %+w", err)
+		}
+		if err := c.Validate(paramObj); err != nil {
+			return xerrors.Errorf("Batch : %+w", err)
+		}
+
+		if c.Param("action") == ":batchCreate" {
+			todos := make([]*Todo, 0, size)
+			for _, item := range paramObj.Items {
+				todos = append(todos, &Todo{
+					Slug:      item.Slug,
+					Task:      item.Task,
+					Status:    item.Status,
+					CreatedAt: time.Time.UTC(time.Now()),
+					UpdatedAt: time.Time.UTC(time.Now()),
+				})
+			}
+			results, err = t.todoService.BatchCreate(c.Request().Context(), todos, mode)
+		} else {
+			results, err = t.todoService.BatchUpdate(c.Request().Context(), paramObj.Items, mode)
+		}
+	case ":batchDelete":
+		paramObj := &TodoBatchDeleteRequest{}
+		if err := c.Bind(paramObj); err != nil {
+			return xerrors.Errorf("Failed to bind parameter into batch object : %+w", ErrInvalidBody.Wrap(err))
+		}
+		mode, size = paramObj.Mode, len(paramObj.Items)
+		if err := t.validateBatchSize(size); err != nil {
+			return xerrors.Errorf("Batch : %+w", err)
+		}
+		if err := c.Validate(paramObj); err != nil {
+			return xerrors.Errorf("Batch : %+w", err)
+		}
+
+		results, err = t.todoService.BatchDelete(c.Request().Context(), paramObj.Items, mode)
+	default:
+		return echo.ErrNotFound
+	}
+
+	if err != nil && results == nil {
+		return xerrors.Errorf("Batch : %+w", err)
+	}
+	if err != nil {
+		logz.Errorf(c.Request().Context(), "%+v", xerrors.Errorf("Batch : %+w", err))
+	}
+
+	if mode == "" {
+		mode = BatchModeAtomic
+	}
+	response := &TodoBatchResponse{Mode: mode, Results: make([]*BatchItemResult, 0, len(results))}
+	status := http.StatusOK
+	for _, result := range results {
+		item := &BatchItemResult{
+			Index:  result.Index,
+			Status: http.StatusOK,
+			ID:     result.ID,
+			Todo:   result.Todo,
+		}
+		if result.Err != nil {
+			// Only what a problem would tell
+			problem := NewProblem(result.Err)
+			item.Status, item.Code, item.Error = problem.Status, problem.Code, problem.Detail
+			response.Failed++
+
+			// The item which made the atomic batch fail decides the status
+			if mode == BatchModeAtomic && status == http.StatusOK && item.Status != http.StatusFailedDependency {
+				status = item.Status
+			}
+		} else {
+			response.Succeeded++
+		}
+		response.Results = append(response.Results, item)
+	}
+
+	switch {
+	case err != nil && status == http.StatusOK:
+		// The transaction itself failed
+		status = http.StatusInternalServerError
+	case err == nil && 0 < response.Failed:
+		status = http.StatusMultiStatus
+	}
+
+	return c.JSON(status, response)
+}
+
+func (t *todoController) validateBatchSize(size int) error {
+	if size == 0 {
+		return xerrors.Errorf("Invalid parameter : %+w", ErrInvalidBatch.Withf("items must not be empty"))
+	}
+	if t.config.BatchMaxSize < size {
+		return xerrors.Errorf("Invalid parameter : %+w", ErrInvalidBatch.Withf("%d items exceed the maximum batch size %d", size, t.config.BatchMaxSize))
+	}
+	return nil
+}
+
+// A failed compare and swap under If-Match is a failed precondition
+func preconditionFailed(err error) error {
+	if xerrors.Is(err, ErrVersionConflict) {
+		return ErrPreconditionFailed.Wrap(err)
+	}
+	return err
+}
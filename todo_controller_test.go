@@ -20,12 +20,12 @@ func TestTodoController(t *testing.T) {
 
 	t.Run("List", eachTestWrapper(func(t *testing.T) {
 		// Setup
-		router := NewRouter(ctx)
+		router := newTestRouter(t, ctx)
 		q := make(url.Values)
 		q.Set("status", "false")
 		q.Set("page", "1")
 		q.Set("pagesize", "10")
-		req := httptest.NewRequest(http.MethodGet, "/todos?"+q.Encode(), nil)
+		req := httptest.NewRequest(http.MethodGet, "/v1/todos?"+q.Encode(), nil)
 		rec := httptest.NewRecorder()
 
 		router.ServeHTTP(rec, req)
@@ -33,16 +33,273 @@ func TestTodoController(t *testing.T) {
 		assert.Equal(t, http.StatusOK, rec.Code)
 		assert.NotEmpty(t, rec.Body.String())
 
-		var responceJson = &[]Todo{}
+		var responceJson = &TodoList{}
 		err := json.Unmarshal([]byte(rec.Body.String()), &responceJson)
 		if err != nil {
 			t.Error(err)
 		} else {
-			assert.NotNil(t, responceJson)
+			assert.NotNil(t, responceJson.Items)
+			assert.Equal(t, 1, responceJson.Page)
+			assert.Equal(t, 10, responceJson.PageSize)
 		}
+		assert.Contains(t, rec.Header().Get(HeaderLink), "rel=\"first\"")
+		assert.Contains(t, rec.Header().Get(HeaderLink), "rel=\"last\"")
 
 	}))
 
+	t.Run("List with filter and sort", eachTestWrapper(func(t *testing.T) {
+		// Setup
+		router := newTestRouter(t, ctx)
+		q := make(url.Values)
+		q.Set("task", "milk")
+		q.Set("sort", "created_at:asc,id:desc")
+		q.Set("page", "1")
+		q.Set("pagesize", "10")
+		req := httptest.NewRequest(http.MethodGet, "/v1/todos?"+q.Encode(), nil)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		// Not whitelisted sort column
+		q.Set("sort", "task")
+		req = httptest.NewRequest(http.MethodGet, "/v1/todos?"+q.Encode(), nil)
+		rec = httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		// Unknown parameter
+		q.Del("sort")
+		q.Set("owner", "me")
+		req = httptest.NewRequest(http.MethodGet, "/v1/todos?"+q.Encode(), nil)
+		rec = httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}))
+
+	t.Run("Search", eachTestWrapper(func(t *testing.T) {
+		// Setup
+		router := newTestRouter(t, ctx)
+		q := make(url.Values)
+		q.Set("q", "milk")
+		q.Set("page", "1")
+		q.Set("pagesize", "10")
+		req := httptest.NewRequest(http.MethodGet, "/v1/todos/search?"+q.Encode(), nil)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var responceJson = &TodoSearchList{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &responceJson)
+		if err != nil {
+			t.Error(err)
+		} else {
+			assert.NotNil(t, responceJson.Items)
+		}
+
+		// Missing query
+		q.Del("q")
+		req = httptest.NewRequest(http.MethodGet, "/v1/todos/search?"+q.Encode(), nil)
+		rec = httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}))
+
+	t.Run("List by cursor", eachTestWrapper(func(t *testing.T) {
+		// Setup
+		router := newTestRouter(t, ctx)
+		q := make(url.Values)
+		q.Set("status", "false")
+		q.Set("cursor", "")
+		q.Set("pagesize", "10")
+		req := httptest.NewRequest(http.MethodGet, "/v1/todos?"+q.Encode(), nil)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var responceJson = &TodoCursorList{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &responceJson)
+		if err != nil {
+			t.Error(err)
+		} else {
+			assert.NotNil(t, responceJson.Items)
+			assert.Equal(t, 10, responceJson.PageSize)
+		}
+
+		// Forged cursor
+		q.Set("cursor", "forged.cursor")
+		req = httptest.NewRequest(http.MethodGet, "/v1/todos?"+q.Encode(), nil)
+		rec = httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}))
+
+	t.Run("Batch", eachTestWrapper(func(t *testing.T) {
+		// Setup
+		router := newTestRouter(t, ctx)
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/todos:batchCreate",
+			strings.NewReader(`{"items": [{"task": "first"}, {"task": "second"}]}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var responceJson = &TodoBatchResponse{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &responceJson)
+		assert.Nil(t, err)
+		assert.Equal(t, BatchModeAtomic, responceJson.Mode)
+		assert.Equal(t, 2, responceJson.Succeeded)
+
+		req = httptest.NewRequest(http.MethodPost, "/v1/todos:batchUpdate",
+			strings.NewReader(`{"items": [{"id": 1, "task": "changed"}, {"id": 999, "task": "missing"}]}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec = httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/v1/todos:batchDelete",
+			strings.NewReader(`{"mode": "partial", "items": [{"id": 1}, {"id": 999}]}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec = httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+
+		responceJson = &TodoBatchResponse{}
+		err = json.Unmarshal([]byte(rec.Body.String()), &responceJson)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, responceJson.Succeeded)
+		assert.Equal(t, 1, responceJson.Failed)
+		assert.Equal(t, http.StatusOK, responceJson.Results[0].Status)
+		assert.Equal(t, http.StatusNotFound, responceJson.Results[1].Status)
+
+		// Empty
+		req = httptest.NewRequest(http.MethodPost, "/v1/todos:batchDelete", strings.NewReader(`{"items": []}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec = httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		// Unknown action
+		req = httptest.NewRequest(http.MethodPost, "/v1/todos:batchArchive", strings.NewReader(`{"items": []}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec = httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}))
+
+	t.Run("By slug", eachTestWrapper(func(t *testing.T) {
+		// Setup
+		router := newTestRouter(t, ctx)
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/todos", strings.NewReader(`{"slug": "client-slug", "task": "by slug"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		// The stored slug is returned
+		createdTodo := &Todo{}
+		err := json.Unmarshal([]byte(rec.Body.String()), createdTodo)
+		assert.Nil(t, err)
+		assert.NotEqual(t, "client-slug", createdTodo.Slug)
+
+		req = httptest.NewRequest(http.MethodGet, "/v1/todos/by-slug/"+createdTodo.Slug, nil)
+		rec = httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPut, "/v1/todos/by-slug/"+createdTodo.Slug, strings.NewReader(`{"task": "changed by slug", "status": true}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec = httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		updatedTodo := &Todo{}
+		err = json.Unmarshal([]byte(rec.Body.String()), updatedTodo)
+		assert.Nil(t, err)
+		assert.Equal(t, createdTodo.ID, updatedTodo.ID)
+		assert.Equal(t, createdTodo.Slug, updatedTodo.Slug)
+		assert.Equal(t, "changed by slug", updatedTodo.Task)
+
+		req = httptest.NewRequest(http.MethodDelete, "/v1/todos/by-slug/"+createdTodo.Slug, nil)
+		rec = httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "{ \"RowsAffected\": 1 }", rec.Body.String())
+
+		req = httptest.NewRequest(http.MethodGet, "/v1/todos/by-slug/"+createdTodo.Slug, nil)
+		rec = httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}))
+
+	t.Run("Validation", eachTestWrapper(func(t *testing.T) {
+		// Setup
+		router := newTestRouter(t, ctx)
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/todos", strings.NewReader(`{"slug": "`+strings.Repeat("a", SlugMaxLength+1)+`", "task": ""}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+		var problem = &Problem{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &problem)
+		assert.Nil(t, err)
+		assert.Equal(t, ErrValidation.Code, problem.Code)
+		assert.Len(t, problem.Errors, 2)
+		assert.Equal(t, "slug", problem.Errors[0].Field)
+		assert.Equal(t, "task", problem.Errors[1].Field)
+
+		// Nothing is stored
+		req = httptest.NewRequest(http.MethodGet, "/v1/todos?page=1&pagesize=10", nil)
+		rec = httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		var responceJson = &TodoList{}
+		err = json.Unmarshal([]byte(rec.Body.String()), &responceJson)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, responceJson.Total)
+	}))
+
 	t.Run("Create Get and Delete", eachTestWrapper(func(t *testing.T) {
 		t.Run("1 Create", func(t *testing.T) {
 			// Create a todo
@@ -59,9 +316,9 @@ func TestTodoController(t *testing.T) {
 
 			// fmt.Printf("%+v", string(todoStr))
 			// Setup
-			router := NewRouter(ctx)
+			router := newTestRouter(t, ctx)
 
-			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(todoStr)))
+			req := httptest.NewRequest(http.MethodPost, "/v1/todos", strings.NewReader(string(todoStr)))
 			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 			rec := httptest.NewRecorder()
 
@@ -73,10 +330,10 @@ func TestTodoController(t *testing.T) {
 
 		t.Run("2 Get", func(t *testing.T) {
 			// Setup
-			router := NewRouter(ctx)
+			router := newTestRouter(t, ctx)
 
 			// ID 1 record Should be created in the above Create
-			req := httptest.NewRequest(http.MethodGet, "/1", nil)
+			req := httptest.NewRequest(http.MethodGet, "/v1/todos/1", nil)
 			rec := httptest.NewRecorder()
 
 			router.ServeHTTP(rec, req)
@@ -87,10 +344,10 @@ func TestTodoController(t *testing.T) {
 
 		t.Run("3 Delete", func(t *testing.T) {
 			// Setup
-			router := NewRouter(ctx)
+			router := newTestRouter(t, ctx)
 
 			// ID 1 record Should be created in the above Create
-			req := httptest.NewRequest(http.MethodDelete, "/1", nil)
+			req := httptest.NewRequest(http.MethodDelete, "/v1/todos/1", nil)
 			rec := httptest.NewRecorder()
 
 			router.ServeHTTP(rec, req)
@@ -101,16 +358,75 @@ func TestTodoController(t *testing.T) {
 
 		t.Run("4 Make sure the data is deleted", func(t *testing.T) {
 			// Setup
-			router := NewRouter(ctx)
+			router := newTestRouter(t, ctx)
 
 			// ID 1 record Should be created in the above Create
-			req := httptest.NewRequest(http.MethodGet, "/1", nil)
+			req := httptest.NewRequest(http.MethodGet, "/v1/todos/1", nil)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+			var problem = &Problem{}
+			err := json.Unmarshal([]byte(rec.Body.String()), &problem)
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusNotFound, problem.Status)
+			assert.Equal(t, ErrTodoNotFound.Code, problem.Code)
+			assert.Equal(t, "/v1/todos/1", problem.Instance)
+		})
+
+		t.Run("5 Trash", func(t *testing.T) {
+			// Setup
+			router := newTestRouter(t, ctx)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/todos/trash?page=1&pagesize=10", nil)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var responceJson = &TodoList{}
+			err := json.Unmarshal([]byte(rec.Body.String()), &responceJson)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, responceJson.Total)
+		})
+
+		t.Run("6 Restore", func(t *testing.T) {
+			// Setup
+			router := newTestRouter(t, ctx)
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/todos/1/restore", nil)
 			rec := httptest.NewRecorder()
 
 			router.ServeHTTP(rec, req)
 
 			assert.Equal(t, http.StatusOK, rec.Code)
-			assert.Equal(t, "{}\n", rec.Body.String())
+
+			// Not in the trash anymore
+			req = httptest.NewRequest(http.MethodPost, "/v1/todos/1/restore", nil)
+			rec = httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		})
+
+		t.Run("7 Purge is only run by the CLI", func(t *testing.T) {
+			// Setup
+			router := newTestRouter(t, ctx)
+
+			for _, path := range []string{"/v1/todos/trash", "/todos/trash"} {
+				req := httptest.NewRequest(http.MethodDelete, path, nil)
+				rec := httptest.NewRecorder()
+
+				router.ServeHTTP(rec, req)
+
+				// Taken for the id of DELETE /todos/:id
+				assert.Equal(t, http.StatusBadRequest, rec.Code, path)
+			}
 		})
 	}))
 
@@ -129,9 +445,9 @@ func TestTodoController(t *testing.T) {
 			}
 
 			// Setup
-			router := NewRouter(ctx)
+			router := newTestRouter(t, ctx)
 
-			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(todoStr)))
+			req := httptest.NewRequest(http.MethodPost, "/v1/todos", strings.NewReader(string(todoStr)))
 			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 			rec := httptest.NewRecorder()
 
@@ -144,10 +460,9 @@ func TestTodoController(t *testing.T) {
 
 		t.Run("2 Get and Update", func(t *testing.T) {
 			// Setup
-			router := NewRouter(ctx)
+			router := newTestRouter(t, ctx)
 			todo := &Todo{
 				ID:     1,
-				Slug:   "test-slug",
 				Task:   "Changed",
 				Status: true,
 			}
@@ -158,7 +473,7 @@ func TestTodoController(t *testing.T) {
 				assert.Nil(t, err)
 			}
 
-			req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(string(todoStr)))
+			req := httptest.NewRequest(http.MethodPut, "/v1/todos/1", strings.NewReader(string(todoStr)))
 			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 			rec := httptest.NewRecorder()
 
@@ -167,14 +482,122 @@ func TestTodoController(t *testing.T) {
 			assert.Equal(t, http.StatusOK, rec.Code)
 			assert.NotEmpty(t, rec.Body.String())
 			fmt.Printf("%+v", rec.Body.String())
+
+			// The slug is read-only
+			req = httptest.NewRequest(http.MethodPut, "/v1/todos/1", strings.NewReader(`{"slug": "other-slug", "task": "Changed"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec = httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
 		})
 
-		t.Run("3 Update Fail", func(t *testing.T) {
+		t.Run("3 Conditional requests", func(t *testing.T) {
 			// Setup
-			router := NewRouter(ctx)
+			router := newTestRouter(t, ctx)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/todos/1", nil)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			etag := rec.Header().Get(HeaderETag)
+			assert.NotEmpty(t, etag)
+
+			// Not modified
+			req = httptest.NewRequest(http.MethodGet, "/v1/todos/1", nil)
+			req.Header.Set(HeaderIfNoneMatch, etag)
+			rec = httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNotModified, rec.Code)
+
+			// Update with the current entity tag
+			todoStr, err := json.Marshal(&Todo{ID: 1, Task: "Changed twice", Status: true})
+			assert.Nil(t, err)
+
+			req = httptest.NewRequest(http.MethodPut, "/v1/todos/1", strings.NewReader(string(todoStr)))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set(HeaderIfMatch, etag)
+			rec = httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.NotEqual(t, etag, rec.Header().Get(HeaderETag))
+
+			// The entity tag is stale now
+			req = httptest.NewRequest(http.MethodPut, "/v1/todos/1", strings.NewReader(string(todoStr)))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set(HeaderIfMatch, etag)
+			rec = httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+			req = httptest.NewRequest(http.MethodDelete, "/v1/todos/1", nil)
+			req.Header.Set(HeaderIfMatch, etag)
+			rec = httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		})
+
+		t.Run("4 Patch", func(t *testing.T) {
+			// Setup
+			router := newTestRouter(t, ctx)
+
+			req := httptest.NewRequest(http.MethodPatch, "/v1/todos/1", strings.NewReader(`{"task": "Patched"}`))
+			req.Header.Set(echo.HeaderContentType, MIMEApplicationMergePatchJSON)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			todo := &Todo{}
+			err := json.Unmarshal([]byte(rec.Body.String()), todo)
+			assert.Nil(t, err)
+			assert.Equal(t, "Patched", todo.Task)
+			assert.Equal(t, true, todo.Status)
+
+			req = httptest.NewRequest(http.MethodPatch, "/v1/todos/1", strings.NewReader(`[{"op": "replace", "path": "/status", "value": false}]`))
+			req.Header.Set(echo.HeaderContentType, MIMEApplicationJSONPatchJSON)
+			rec = httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			// Read only field
+			req = httptest.NewRequest(http.MethodPatch, "/v1/todos/1", strings.NewReader(`{"id": 5}`))
+			req.Header.Set(echo.HeaderContentType, MIMEApplicationMergePatchJSON)
+			rec = httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+			// Unsupported media type
+			req = httptest.NewRequest(http.MethodPatch, "/v1/todos/1", strings.NewReader(`task=Patched`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+			rec = httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		})
+
+		t.Run("5 Update Fail", func(t *testing.T) {
+			// Setup
+			router := newTestRouter(t, ctx)
 			todo := &Todo{
 				ID:     2,
-				Slug:   "test-slug",
 				Task:   "Changed",
 				Status: true,
 			}
@@ -185,13 +608,13 @@ func TestTodoController(t *testing.T) {
 				assert.Nil(t, err)
 			}
 
-			req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(string(todoStr)))
+			req := httptest.NewRequest(http.MethodPut, "/v1/todos/2", strings.NewReader(string(todoStr)))
 			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 			rec := httptest.NewRecorder()
 
 			router.ServeHTTP(rec, req)
 
-			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, http.StatusNotFound, rec.Code)
 			assert.NotEmpty(t, rec.Body.String())
 			//fmt.Printf("%+v", rec.Body.String())
 		})
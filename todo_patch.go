@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"golang.org/x/xerrors"
+	"mime"
+	"reflect"
+)
+
+const (
+	// RFC 7396 JSON Merge Patch
+	// https://datatracker.ietf.org/doc/html/rfc7396
+	MIMEApplicationMergePatchJSON = "application/merge-patch+json"
+	// RFC 6902 JSON Patch
+	// https://datatracker.ietf.org/doc/html/rfc6902
+	MIMEApplicationJSONPatchJSON = "application/json-patch+json"
+)
+
+var (
+	ErrInvalidPatch     = NewDomainError(KindValidation, "invalid_patch", "invalid patch")
+	ErrUnsupportedPatch = NewDomainError(KindUnsupported, "unsupported_patch", "unsupported patch media type")
+)
+
+type (
+	// Fields of the todo to be changed. Nil fields are left as they are.
+	// The slug is a stable identifier and can not be patched.
+	TodoPatch struct {
+		Task   *string
+		Status *bool
+	}
+)
+
+// Apply the patch document to the todo and collect the fields it changed.
+// application/json is treated as JSON Merge Patch.
+// Changing any other field than task and status is rejected.
+func NewTodoPatch(todo *Todo, contentType string, body []byte) (*TodoPatch, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, xerrors.Errorf("NewTodoPatch : %+w", ErrUnsupportedPatch.Wrap(err))
+	}
+
+	original, err := json.Marshal(todo)
+	if err != nil {
+		return nil, xerrors.Errorf("NewTodoPatch : %+w", err)
+	}
+
+	var patched []byte
+	switch mediaType {
+	case MIMEApplicationMergePatchJSON, "application/json":
+		patched, err = jsonpatch.MergePatch(original, body)
+	case MIMEApplicationJSONPatchJSON:
+		var patch jsonpatch.Patch
+		patch, err = jsonpatch.DecodePatch(body)
+		if err == nil {
+			patched, err = patch.Apply(original)
+		}
+	default:
+		return nil, xerrors.Errorf("NewTodoPatch : %+w", ErrUnsupportedPatch.Withf("unsupported patch media type %s", mediaType))
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("NewTodoPatch : %+w", ErrInvalidPatch.Wrap(err))
+	}
+
+	before := map[string]interface{}{}
+	if err := json.Unmarshal(original, &before); err != nil {
+		return nil, xerrors.Errorf("NewTodoPatch : %+w", err)
+	}
+	after := map[string]interface{}{}
+	if err := json.Unmarshal(patched, &after); err != nil {
+		return nil, xerrors.Errorf("NewTodoPatch : %+w", ErrInvalidPatch.Wrap(err))
+	}
+
+	patch := &TodoPatch{}
+	fields := map[string]interface{}{
+		"task":   &patch.Task,
+		"status": &patch.Status,
+	}
+
+	// Fields which are gone
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			return nil, xerrors.Errorf("NewTodoPatch : %+w", ErrInvalidPatch.Withf("%s can not be removed", key))
+		}
+	}
+
+	for key, value := range after {
+		if reflect.DeepEqual(before[key], value) {
+			continue
+		}
+
+		dest, ok := fields[key]
+		if !ok {
+			return nil, xerrors.Errorf("NewTodoPatch : %+w", ErrInvalidPatch.Withf("%s can not be changed", key))
+		}
+		if value == nil {
+			return nil, xerrors.Errorf("NewTodoPatch : %+w", ErrInvalidPatch.Withf("%s must not be null", key))
+		}
+
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, xerrors.Errorf("NewTodoPatch : %+w", err)
+		}
+		if err := json.Unmarshal(raw, dest); err != nil {
+			return nil, xerrors.Errorf("NewTodoPatch : %+w", ErrInvalidPatch.Withf("%s has a wrong type", key).Wrap(err))
+		}
+	}
+
+	return patch, nil
+}
+
+// No field to be changed
+func (p *TodoPatch) IsEmpty() bool {
+	return p.Task == nil && p.Status == nil
+}
+
+// Validate the changed fields with the rules of Todo
+func (p *TodoPatch) Validate() error {
+	todo := &Todo{}
+	var fields []string
+	if p.Task != nil {
+		todo.Task = *p.Task
+		fields = append(fields, "Task")
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	if err := validate.StructPartial(todo, fields...); err != nil {
+		return xerrors.Errorf("Validate : %+w", validationError(ErrInvalidPatch, err))
+	}
+	return nil
+}
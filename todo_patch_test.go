@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+	"strings"
+	"testing"
+)
+
+func TestTodoPatch(t *testing.T) {
+	t.Parallel()
+
+	todo := &Todo{ID: 1, Slug: "slug", Task: "task", Status: false, Version: 1}
+
+	t.Run("JSON Merge Patch", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := NewTodoPatch(todo, MIMEApplicationMergePatchJSON, []byte(`{"task": "changed"}`))
+		assert.Nil(t, err)
+		assert.Equal(t, "changed", *patch.Task)
+		assert.Nil(t, patch.Status)
+
+		// Same value is not a change
+		patch, err = NewTodoPatch(todo, MIMEApplicationMergePatchJSON+"; charset=utf-8", []byte(`{"task": "task"}`))
+		assert.Nil(t, err)
+		assert.True(t, patch.IsEmpty())
+	})
+
+	t.Run("JSON Patch", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := NewTodoPatch(todo, MIMEApplicationJSONPatchJSON, []byte(
+			`[{"op": "test", "path": "/task", "value": "task"}, {"op": "replace", "path": "/status", "value": true}]`))
+		assert.Nil(t, err)
+		assert.True(t, *patch.Status)
+		assert.Nil(t, patch.Task)
+
+		// Failed test operation
+		_, err = NewTodoPatch(todo, MIMEApplicationJSONPatchJSON, []byte(`[{"op": "test", "path": "/task", "value": "other"}]`))
+		assert.True(t, xerrors.Is(err, ErrInvalidPatch))
+	})
+
+	t.Run("Reject invalid patch", func(t *testing.T) {
+		t.Parallel()
+
+		for _, body := range []string{
+			`{"id": 2}`,
+			`{"slug": "other"}`,
+			`{"version": 5}`,
+			`{"task": null}`,
+			`{"status": "yes"}`,
+			`{"unknown": 1}`,
+			`not json`,
+		} {
+			_, err := NewTodoPatch(todo, MIMEApplicationMergePatchJSON, []byte(body))
+			assert.True(t, xerrors.Is(err, ErrInvalidPatch), body)
+		}
+
+		_, err := NewTodoPatch(todo, "text/plain", []byte(`{"task": "changed"}`))
+		assert.True(t, xerrors.Is(err, ErrUnsupportedPatch))
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		t.Parallel()
+
+		empty := ""
+		assert.True(t, xerrors.Is((&TodoPatch{Task: &empty}).Validate(), ErrInvalidPatch))
+
+		long := strings.Repeat("a", TaskMaxLength+1)
+		assert.True(t, xerrors.Is((&TodoPatch{Task: &long}).Validate(), ErrInvalidPatch))
+
+		task := "task"
+		assert.Nil(t, (&TodoPatch{Task: &task}).Validate())
+	})
+}
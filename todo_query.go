@@ -0,0 +1,319 @@
+package main
+
+import (
+	"golang.org/x/xerrors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	QueryStatus      = "status"
+	QuerySlugPrefix  = "slug_prefix"
+	QueryTask        = "task"
+	QueryCreatedFrom = "created_from"
+	QueryCreatedTo   = "created_to"
+	QueryUpdatedFrom = "updated_from"
+	QueryUpdatedTo   = "updated_to"
+	QuerySort        = "sort"
+
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+var (
+	ErrInvalidQuery = NewDomainError(KindInvalid, "invalid_query", "invalid query")
+
+	// Query parameters accepted by GET /todos
+	todoQueryParams = map[string]bool{
+		QueryStatus:      true,
+		QuerySlugPrefix:  true,
+		QueryTask:        true,
+		QueryCreatedFrom: true,
+		QueryCreatedTo:   true,
+		QueryUpdatedFrom: true,
+		QueryUpdatedTo:   true,
+		QuerySort:        true,
+		QueryPage:        true,
+		QueryPageSize:    true,
+		QueryCursor:      true,
+	}
+
+	// Columns which todos can be sorted by.
+	// Only these names ever reach ORDER BY.
+	todoSortColumns = map[string]bool{
+		"id":         true,
+		"slug":       true,
+		"status":     true,
+		"created_at": true,
+		"updated_at": true,
+	}
+
+	// Applied when no sort is given
+	defaultTodoSort = []SortOrder{
+		{Column: "updated_at", Desc: true},
+		{Column: "id", Desc: true},
+	}
+)
+
+type (
+	// Optional conditions of todo listing. Zero values mean no condition.
+	TodoFilter struct {
+		Status      *bool
+		SlugPrefix  string
+		Task        string
+		CreatedFrom *time.Time
+		CreatedTo   *time.Time
+		UpdatedFrom *time.Time
+		UpdatedTo   *time.Time
+	}
+
+	SortOrder struct {
+		Column string
+		Desc   bool
+	}
+)
+
+// Build TodoFilter from query parameters.
+// Unknown parameters and malformed values are rejected.
+func ParseTodoFilter(query url.Values) (*TodoFilter, error) {
+	for key := range query {
+		if !todoQueryParams[key] {
+			return nil, xerrors.Errorf("ParseTodoFilter : %+w", ErrInvalidQuery.Withf("unknown query parameter %s", key))
+		}
+	}
+
+	filter := &TodoFilter{
+		SlugPrefix: query.Get(QuerySlugPrefix),
+		Task:       query.Get(QueryTask),
+	}
+
+	if value := query.Get(QueryStatus); value != "" {
+		status, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, xerrors.Errorf("ParseTodoFilter : %+w", ErrInvalidQuery.Withf("%s must be boolean : %s", QueryStatus, value))
+		}
+		filter.Status = &status
+	}
+
+	times := map[string]**time.Time{
+		QueryCreatedFrom: &filter.CreatedFrom,
+		QueryCreatedTo:   &filter.CreatedTo,
+		QueryUpdatedFrom: &filter.UpdatedFrom,
+		QueryUpdatedTo:   &filter.UpdatedTo,
+	}
+	for key, dest := range times {
+		value := query.Get(key)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, xerrors.Errorf("ParseTodoFilter : %+w", ErrInvalidQuery.Withf("%s must be RFC3339 : %s", key, value))
+		}
+		parsed = parsed.UTC()
+		*dest = &parsed
+	}
+
+	return filter, nil
+}
+
+// Parse sort parameter such as "updated_at:desc,id".
+// The direction is ascending when omitted.
+func ParseSort(value string) ([]SortOrder, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var orders []SortOrder
+	for _, field := range strings.Split(value, ",") {
+		column, direction := field, SortAsc
+		if i := strings.Index(field, ":"); 0 <= i {
+			column, direction = field[:i], strings.ToLower(field[i+1:])
+		}
+
+		if !todoSortColumns[column] {
+			return nil, xerrors.Errorf("ParseSort : %+w", ErrInvalidQuery.Withf("unknown sort column %s", column))
+		}
+		if direction != SortAsc && direction != SortDesc {
+			return nil, xerrors.Errorf("ParseSort : %+w", ErrInvalidQuery.Withf("unknown sort direction %s", direction))
+		}
+
+		orders = append(orders, SortOrder{Column: column, Desc: direction == SortDesc})
+	}
+
+	return orders, nil
+}
+
+// GORM scope applying the filter
+func (f *TodoFilter) Scope(db *gorm.DB) *gorm.DB {
+	if f == nil {
+		return db
+	}
+	if f.Status != nil {
+		db = db.Where("status = ?", *f.Status)
+	}
+	// Case insensitive like the utf8mb4 collation of MySQL
+	like := "LIKE"
+	if db.Dialector.Name() == DB_DRIVER_POSTGRES {
+		like = "ILIKE"
+	}
+	if f.SlugPrefix != "" {
+		db = db.Where("slug "+like+" ? ESCAPE '!'", escapeLike(f.SlugPrefix)+"%")
+	}
+	if f.Task != "" {
+		db = db.Where("task "+like+" ? ESCAPE '!'", "%"+escapeLike(f.Task)+"%")
+	}
+	if f.CreatedFrom != nil {
+		db = db.Where("created_at >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		db = db.Where("created_at <= ?", *f.CreatedTo)
+	}
+	if f.UpdatedFrom != nil {
+		db = db.Where("updated_at >= ?", *f.UpdatedFrom)
+	}
+	if f.UpdatedTo != nil {
+		db = db.Where("updated_at <= ?", *f.UpdatedTo)
+	}
+	return db
+}
+
+// In-memory equivalent of Scope. Strings compare case-insensitively
+// like the utf8mb4_general_ci columns.
+func (f *TodoFilter) Match(todo *Todo) bool {
+	if f == nil {
+		return true
+	}
+	if f.Status != nil && todo.Status != *f.Status {
+		return false
+	}
+	if f.SlugPrefix != "" && !hasPrefixFold(todo.Slug, f.SlugPrefix) {
+		return false
+	}
+	if f.Task != "" && indexFold(todo.Task, f.Task) < 0 {
+		return false
+	}
+	if f.CreatedFrom != nil && todo.CreatedAt.Before(*f.CreatedFrom) {
+		return false
+	}
+	if f.CreatedTo != nil && todo.CreatedAt.After(*f.CreatedTo) {
+		return false
+	}
+	if f.UpdatedFrom != nil && todo.UpdatedAt.Before(*f.UpdatedFrom) {
+		return false
+	}
+	if f.UpdatedTo != nil && todo.UpdatedAt.After(*f.UpdatedTo) {
+		return false
+	}
+	return true
+}
+
+// The given orders ended by id, so that the rows never tie and pages neither skip
+// nor repeat rows. id follows the direction of the last column.
+func withTiebreaker(orders []SortOrder) []SortOrder {
+	if len(orders) == 0 {
+		return defaultTodoSort
+	}
+	for _, order := range orders {
+		if order.Column == "id" {
+			return orders
+		}
+	}
+
+	tiebroken := make([]SortOrder, 0, len(orders)+1)
+	tiebroken = append(tiebroken, orders...)
+	return append(tiebroken, SortOrder{Column: "id", Desc: orders[len(orders)-1].Desc})
+}
+
+// Build ORDER BY from whitelisted columns only.
+// Column names are quoted by GORM, never concatenated.
+func sortScope(orders []SortOrder) (func(db *gorm.DB) *gorm.DB, error) {
+	orders = withTiebreaker(orders)
+
+	columns := make([]clause.OrderByColumn, 0, len(orders))
+	for _, order := range orders {
+		if !todoSortColumns[order.Column] {
+			return nil, xerrors.Errorf("sortScope : %+w", ErrInvalidQuery.Withf("unknown sort column %s", order.Column))
+		}
+		columns = append(columns, clause.OrderByColumn{
+			Column: clause.Column{Name: order.Column},
+			Desc:   order.Desc,
+		})
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Clauses(clause.OrderBy{Columns: columns})
+	}, nil
+}
+
+// In-memory equivalent of sortScope
+func sortTodos(todos []*Todo, orders []SortOrder) error {
+	orders = withTiebreaker(orders)
+
+	for _, order := range orders {
+		if !todoSortColumns[order.Column] {
+			return xerrors.Errorf("sortTodos : %+w", ErrInvalidQuery.Withf("unknown sort column %s", order.Column))
+		}
+	}
+
+	sort.SliceStable(todos, func(i, j int) bool {
+		for _, order := range orders {
+			c := compareTodoColumn(todos[i], todos[j], order.Column)
+			if c == 0 {
+				continue
+			}
+			if order.Desc {
+				return 0 < c
+			}
+			return c < 0
+		}
+		return false
+	})
+	return nil
+}
+
+// Three-way comparison of a whitelisted column
+func compareTodoColumn(a *Todo, b *Todo, column string) int {
+	switch column {
+	case "id":
+		return compareInt64(a.ID, b.ID)
+	case "slug":
+		return strings.Compare(strings.ToLower(a.Slug), strings.ToLower(b.Slug))
+	case "status":
+		return compareInt64(boolToInt64(a.Status), boolToInt64(b.Status))
+	case "created_at":
+		return compareInt64(a.CreatedAt.UnixNano(), b.CreatedAt.UnixNano())
+	case "updated_at":
+		return compareInt64(a.UpdatedAt.UnixNano(), b.UpdatedAt.UnixNano())
+	}
+	return 0
+}
+
+func compareInt64(a int64, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case b < a:
+		return 1
+	}
+	return 0
+}
+
+func boolToInt64(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Escape LIKE wildcards so that user input matches literally.
+// SQLite has no default escape character and MySQL treats backslashes in literals specially,
+// so ! is given with ESCAPE explicitly.
+func escapeLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
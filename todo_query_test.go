@@ -0,0 +1,89 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+	"net/url"
+	"testing"
+)
+
+func TestTodoQuery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ParseTodoFilter", func(t *testing.T) {
+		t.Parallel()
+
+		q := make(url.Values)
+		q.Set("status", "true")
+		q.Set("slug_prefix", "abc")
+		q.Set("task", "milk")
+		q.Set("created_from", "2021-09-01T00:00:00Z")
+		q.Set("updated_to", "2021-09-02T09:00:00+09:00")
+		q.Set("page", "1")
+
+		filter, err := ParseTodoFilter(q)
+		assert.Nil(t, err)
+		assert.True(t, *filter.Status)
+		assert.Equal(t, "abc", filter.SlugPrefix)
+		assert.Equal(t, "milk", filter.Task)
+		assert.Equal(t, "2021-09-01T00:00:00Z", filter.CreatedFrom.Format("2006-01-02T15:04:05Z07:00"))
+		assert.Equal(t, "2021-09-02T00:00:00Z", filter.UpdatedTo.Format("2006-01-02T15:04:05Z07:00"))
+		assert.Nil(t, filter.CreatedTo)
+		assert.Nil(t, filter.UpdatedFrom)
+
+		// Everything is optional
+		filter, err = ParseTodoFilter(url.Values{})
+		assert.Nil(t, err)
+		assert.Nil(t, filter.Status)
+	})
+
+	t.Run("ParseTodoFilter rejects invalid input", func(t *testing.T) {
+		t.Parallel()
+
+		for _, q := range []url.Values{
+			{"unknown": []string{"1"}},
+			{"status": []string{"maybe"}},
+			{"created_from": []string{"yesterday"}},
+		} {
+			_, err := ParseTodoFilter(q)
+			assert.True(t, xerrors.Is(err, ErrInvalidQuery), q.Encode())
+		}
+	})
+
+	t.Run("ParseSort", func(t *testing.T) {
+		t.Parallel()
+
+		orders, err := ParseSort("updated_at:desc,id,slug:ASC")
+		assert.Nil(t, err)
+		assert.Equal(t, []SortOrder{
+			{Column: "updated_at", Desc: true},
+			{Column: "id"},
+			{Column: "slug"},
+		}, orders)
+
+		orders, err = ParseSort("")
+		assert.Nil(t, err)
+		assert.Nil(t, orders)
+
+		for _, value := range []string{"task", "id:sideways", "id;DROP TABLE todos", "updated_at DESC"} {
+			_, err = ParseSort(value)
+			assert.True(t, xerrors.Is(err, ErrInvalidQuery), value)
+		}
+	})
+
+	t.Run("withTiebreaker", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, defaultTodoSort, withTiebreaker(nil))
+		assert.Equal(t, []SortOrder{{Column: "status"}, {Column: "slug", Desc: true}, {Column: "id", Desc: true}},
+			withTiebreaker([]SortOrder{{Column: "status"}, {Column: "slug", Desc: true}}))
+		assert.Equal(t, []SortOrder{{Column: "id"}, {Column: "status", Desc: true}},
+			withTiebreaker([]SortOrder{{Column: "id"}, {Column: "status", Desc: true}}))
+	})
+
+	t.Run("escapeLike", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, "100!%!_a!!b\\c", escapeLike("100%_a!b\\c"))
+	})
+}
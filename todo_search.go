@@ -0,0 +1,272 @@
+package main
+
+import (
+	"golang.org/x/xerrors"
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	QuerySearch     = "q"
+	QuerySearchMode = "mode"
+
+	SearchModeNatural = "natural"
+	SearchModeBoolean = "boolean"
+
+	// Runes kept on each side of the first hit in a snippet
+	snippetRadius  = 60
+	highlightOpen  = "<mark>"
+	highlightClose = "</mark>"
+
+	// Indexed by todos_task_fulltext on PostgreSQL
+	todosTSVector = "to_tsvector('simple', task)"
+)
+
+var (
+	ErrInvalidSearch = NewDomainError(KindInvalid, "invalid_search", "invalid search")
+
+	// MATCH ... AGAINST modifiers. Only these ever reach the query.
+	searchModifiers = map[string]string{
+		SearchModeNatural: "IN NATURAL LANGUAGE MODE",
+		SearchModeBoolean: "IN BOOLEAN MODE",
+	}
+)
+
+type (
+	SearchOptions struct {
+		// natural or boolean, natural when empty
+		Mode     string
+		Page     int
+		PageSize int
+		Filter   *TodoFilter
+	}
+
+	// Condition and relevance of a full-text search in the dialect of DB_DRIVER.
+	// Both take Args.
+	fullTextMatch struct {
+		Where string
+		Score string
+		Args  []interface{}
+	}
+
+	TodoSearchResult struct {
+		Todo
+		Score   float64 `json:"score" gorm:"column:score"`
+		Snippet string  `json:"snippet" gorm:"-"`
+	}
+)
+
+// MATCH ... AGAINST modifier of the mode
+func searchModifier(mode string) (string, error) {
+	if mode == "" {
+		mode = SearchModeNatural
+	}
+	modifier, ok := searchModifiers[mode]
+	if !ok {
+		return "", xerrors.Errorf("searchModifier : %+w", ErrInvalidSearch.Withf("unknown search mode %s", mode))
+	}
+	return modifier, nil
+}
+
+// Full-text search of the query. MySQL uses the FULLTEXT index todos_task_fulltext,
+// PostgreSQL the GIN index of the same name with the query translated into a tsquery,
+// SQLite the FTS5 table todos_fts with the query translated into FTS5 syntax.
+func newFullTextMatch(driver string, query string, mode string) (*fullTextMatch, error) {
+	modifier, err := searchModifier(mode)
+	if err != nil {
+		return nil, xerrors.Errorf("newFullTextMatch : %+w", err)
+	}
+
+	switch driver {
+	case DB_DRIVER_POSTGRES:
+		// Same expression as the index so that the planner uses it
+		return &fullTextMatch{
+			Where: todosTSVector + " @@ to_tsquery('simple', ?)",
+			Score: "ts_rank(" + todosTSVector + ", to_tsquery('simple', ?))",
+			Args:  []interface{}{tsqueryExpression(query, mode)},
+		}, nil
+	case DB_DRIVER_SQLITE:
+		return &fullTextMatch{
+			Where: "id IN (SELECT rowid FROM todos_fts WHERE todos_fts MATCH ?)",
+			// bm25 is lower for better matches
+			Score: "(SELECT -bm25(todos_fts) FROM todos_fts WHERE todos_fts MATCH ? AND rowid = todos.id)",
+			Args:  []interface{}{fts5Expression(query, mode)},
+		}, nil
+	}
+
+	match := "MATCH (task) AGAINST (? " + modifier + ")"
+	return &fullTextMatch{Where: match, Score: match, Args: []interface{}{query}}, nil
+}
+
+// Translate the query into tsquery syntax with the same rules as fts5Expression.
+// https://www.postgresql.org/docs/current/datatype-textsearch.html#DATATYPE-TSQUERY
+func tsqueryExpression(query string, mode string) string {
+	var required, optional, excluded []string
+	for _, word := range strings.Fields(query) {
+		operator, prefix, word := parseSearchWord(word, mode)
+		if word == "" {
+			continue
+		}
+
+		// Quoted so that tsquery operators in the input are taken literally
+		term := "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(word) + "'"
+		if prefix {
+			term += ":*"
+		}
+
+		switch operator {
+		case '+':
+			required = append(required, term)
+		case '-':
+			excluded = append(excluded, term)
+		default:
+			optional = append(optional, term)
+		}
+	}
+
+	expression := strings.Join(optional, " | ")
+	if 0 < len(required) {
+		expression = strings.Join(required, " & ")
+	}
+	if 0 < len(excluded) {
+		expression = "(" + expression + ") & !(" + strings.Join(excluded, " | ") + ")"
+	}
+	return expression
+}
+
+// Translate the query into FTS5 syntax. Natural mode hits any word, boolean mode
+// requires +words, excludes -words and matches the prefix of words*.
+// https://www.sqlite.org/fts5.html#full_text_query_syntax
+func fts5Expression(query string, mode string) string {
+	var required, optional, excluded []string
+	for _, word := range strings.Fields(query) {
+		operator, prefix, word := parseSearchWord(word, mode)
+		if word == "" {
+			continue
+		}
+
+		// Quoted so that FTS5 operators in the input are taken literally
+		term := `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+		if prefix {
+			term += "*"
+		}
+
+		switch operator {
+		case '+':
+			required = append(required, term)
+		case '-':
+			excluded = append(excluded, term)
+		default:
+			optional = append(optional, term)
+		}
+	}
+
+	expression := strings.Join(optional, " OR ")
+	if 0 < len(required) {
+		expression = strings.Join(required, " AND ")
+	}
+	if 0 < len(excluded) {
+		expression = "(" + expression + ") NOT (" + strings.Join(excluded, " OR ") + ")"
+	}
+	return expression
+}
+
+// Split a word of the query into its boolean mode operator, its trailing * and the word.
+// Natural mode has no operators, the same characters are only trimmed off.
+func parseSearchWord(word string, mode string) (operator byte, prefix bool, term string) {
+	if mode == SearchModeBoolean {
+		operator, prefix = word[0], strings.HasSuffix(word, "*")
+	}
+	return operator, prefix, strings.Trim(word, "+-<>()~*\"@")
+}
+
+// Words of the query the way the query builders read them.
+// Excluded words are dropped since they never hit.
+func searchTerms(query string, mode string) []string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		operator, _, word := parseSearchWord(word, mode)
+		if operator != '-' && word != "" {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// Cut the text around the first hit of the terms and wrap every hit in <mark>.
+// The text is HTML escaped so that only the highlight tags are markup.
+func Snippet(text string, terms []string) string {
+	// Find the first hit, the head of the text when nothing hits
+	first := -1
+	for _, term := range terms {
+		if i := indexFold(text, term); 0 <= i && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	// Cut the window on rune boundaries
+	start := first
+	for n := 0; n < snippetRadius && 0 < start; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	end := first
+	for n := 0; n < snippetRadius*2 && end < len(text); n++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+
+	snippet := highlight(text[start:end], terms)
+	if 0 < start {
+		snippet = "…" + snippet
+	}
+	if end < len(text) {
+		snippet = snippet + "…"
+	}
+	return snippet
+}
+
+func highlight(text string, terms []string) string {
+	var builder strings.Builder
+	for pos := 0; pos < len(text); {
+		// Longest term hitting at the current position
+		hit := 0
+		for _, term := range terms {
+			if hit < len(term) && hasPrefixFold(text[pos:], term) {
+				hit = len(term)
+			}
+		}
+
+		if 0 < hit {
+			builder.WriteString(highlightOpen)
+			builder.WriteString(html.EscapeString(text[pos : pos+hit]))
+			builder.WriteString(highlightClose)
+			pos += hit
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(text[pos:])
+		builder.WriteString(html.EscapeString(text[pos : pos+size]))
+		pos += size
+	}
+	return builder.String()
+}
+
+// Case insensitive strings.Index
+func indexFold(s string, substr string) int {
+	for i := range s {
+		if hasPrefixFold(s[i:], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Case insensitive strings.HasPrefix
+func hasPrefixFold(s string, prefix string) bool {
+	return prefix != "" && len(prefix) <= len(s) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+	"strings"
+	"testing"
+)
+
+func TestTodoSearch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("searchTerms", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, []string{"milk", "fresh", "bread"}, searchTerms(`+milk -tea "fresh" bread*`, SearchModeBoolean))
+		assert.Nil(t, searchTerms("  ", SearchModeBoolean))
+
+		// Highlighted as the query builders match them
+		assert.Equal(t, []string{"milk", "tea"}, searchTerms("+milk -tea", SearchModeNatural))
+		assert.Equal(t, []string{"foo"}, searchTerms("-foo", SearchModeNatural))
+		assert.Equal(t, []string{"foo"}, searchTerms("foo-", SearchModeBoolean))
+	})
+
+	t.Run("fts5Expression", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, `"milk" OR "tea"`, fts5Expression("milk tea", SearchModeNatural))
+		assert.Equal(t, `("milk") NOT ("tea")`, fts5Expression("+milk -tea", SearchModeBoolean))
+		assert.Equal(t, `"bre"* OR "a""b"`, fts5Expression(`bre* a"b`, SearchModeBoolean))
+
+		// Operators are plain words in natural mode
+		assert.Equal(t, `"milk" OR "tea"`, fts5Expression("+milk -tea", SearchModeNatural))
+	})
+
+	t.Run("tsqueryExpression", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, `'milk' | 'tea'`, tsqueryExpression("milk tea", SearchModeNatural))
+		assert.Equal(t, `('milk') & !('tea')`, tsqueryExpression("+milk -tea", SearchModeBoolean))
+		assert.Equal(t, `'bre':* | 'it''s'`, tsqueryExpression(`bre* it's`, SearchModeBoolean))
+		// Backslashes never end the quoted word
+		assert.Equal(t, `'foo\\'`, tsqueryExpression(`foo\`, SearchModeNatural))
+		assert.Equal(t, `'c:\\tmp'`, tsqueryExpression(`c:\tmp`, SearchModeNatural))
+	})
+
+	t.Run("newFullTextMatch", func(t *testing.T) {
+		t.Parallel()
+
+		match, err := newFullTextMatch(DB_DRIVER_MYSQL, "milk", SearchModeBoolean)
+		assert.Nil(t, err)
+		assert.Equal(t, "MATCH (task) AGAINST (? IN BOOLEAN MODE)", match.Where)
+		assert.Equal(t, []interface{}{"milk"}, match.Args)
+
+		match, err = newFullTextMatch(DB_DRIVER_SQLITE, "milk", "")
+		assert.Nil(t, err)
+		assert.Contains(t, match.Where, "todos_fts MATCH ?")
+		assert.Equal(t, []interface{}{`"milk"`}, match.Args)
+
+		match, err = newFullTextMatch(DB_DRIVER_POSTGRES, "milk", SearchModeNatural)
+		assert.Nil(t, err)
+		assert.Equal(t, "to_tsvector('simple', task) @@ to_tsquery('simple', ?)", match.Where)
+		assert.Equal(t, []interface{}{"'milk'"}, match.Args)
+
+		_, err = newFullTextMatch(DB_DRIVER_MYSQL, "milk", "regexp")
+		assert.True(t, xerrors.Is(err, ErrInvalidSearch))
+	})
+
+	t.Run("Snippet", func(t *testing.T) {
+		t.Parallel()
+
+		// Highlight every hit and escape HTML
+		assert.Equal(t,
+			"buy &lt;b&gt;<mark>Milk</mark>&lt;/b&gt; and <mark>milk</mark>",
+			Snippet("buy <b>Milk</b> and milk", []string{"milk"}))
+
+		// Cut around the first hit
+		text := strings.Repeat("a", 200) + " milk " + strings.Repeat("b", 200)
+		snippet := Snippet(text, []string{"milk"})
+		assert.True(t, strings.HasPrefix(snippet, "…"))
+		assert.True(t, strings.HasSuffix(snippet, "…"))
+		assert.Contains(t, snippet, "<mark>milk</mark>")
+
+		// Head of the text when nothing hits
+		assert.Equal(t, "walk the dog", Snippet("walk the dog", []string{"milk"}))
+	})
+}
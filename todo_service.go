@@ -3,43 +3,80 @@ package main
 import (
 	"context"
 	"golang.org/x/xerrors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"time"
 )
 
+var (
+	ErrVersionConflict = NewDomainError(KindConflict, "version_conflict", "the todo has been changed by someone else")
+)
+
 type (
 	TodoService interface {
-		List(status bool, page, pagesize int, order string) (todos []*Todo, totalRows int, err error)
-		Create(todo *Todo) (*Todo, error)
-		CreateInBatches(todos []Todo) ([]Todo, error)
-		Delete(ID int64) (rowsAffected int64, err error)
-		Get(id int64) (*Todo, error)
-		Update(todo *Todo) (*Todo, error)
+		List(ctx context.Context, filter *TodoFilter, page, pagesize int, orders []SortOrder) (todos []*Todo, totalRows int, err error)
+		ListByCursor(ctx context.Context, filter *TodoFilter, cursor string, pagesize int) (todos []*Todo, nextCursor string, err error)
+		Search(ctx context.Context, query string, opts *SearchOptions) (results []*TodoSearchResult, totalRows int, err error)
+		Create(ctx context.Context, todo *Todo) (*Todo, error)
+		CreateInBatches(ctx context.Context, todos []Todo) ([]Todo, error)
+		Delete(ctx context.Context, ID int64) (rowsAffected int64, err error)
+		DeleteVersion(ctx context.Context, ID int64, version int64) (rowsAffected int64, err error)
+		ListTrash(ctx context.Context, page, pagesize int) (todos []*Todo, totalRows int, err error)
+		Restore(ctx context.Context, ID int64) (*Todo, error)
+		PurgeTrash(ctx context.Context, retention time.Duration) (rowsAffected int64, err error)
+		Get(ctx context.Context, id int64) (*Todo, error)
+		GetBySlug(ctx context.Context, slug string) (*Todo, error)
+		Update(ctx context.Context, todo *Todo) (*Todo, error)
+		Patch(ctx context.Context, ID int64, version int64, patch *TodoPatch) (*Todo, error)
+		BatchCreate(ctx context.Context, todos []*Todo, mode string) ([]*BatchResult, error)
+		BatchUpdate(ctx context.Context, todos []*Todo, mode string) ([]*BatchResult, error)
+		BatchDelete(ctx context.Context, items []*BatchDeleteItem, mode string) ([]*BatchResult, error)
 	}
 
 	todoService struct {
-		Repository CloudSQL
+		repository Repository
+		config     *applicationConfig
 	}
 
 	Todo struct {
 		// field named `ID` will be used as a primary field by default
 		// https://gorm.io/docs/conventions.html#ID-as-Primary-Key
 		ID        int64     `form:"id" json:"id" gorm:"primary_key;AUTO_INCREMENT;column:id;type:bigint;" faker:"-"`
-		Slug      string    `form:"slug" json:"slug" gorm:"column:slug;type:varchar;" faker:"uuid_hyphenated"`
-		Task      string    `form:"task" json:"task" gorm:"column:task;type:text;"`
+		Slug      string    `form:"slug" json:"slug" gorm:"column:slug;type:varchar;" faker:"uuid_hyphenated" validate:"omitempty,slug_length,slug"`
+		Task      string    `form:"task" json:"task" gorm:"column:task;type:text;" validate:"required,task_length"`
 		Status    bool      `form:"status" json:"status" gorm:"column:status;type:tinyint;default:0;"`
 		CreatedAt time.Time `gorm:"column:created_at;type:timestamp;default:CURRENT_TIMESTAMP;" faker:"-"`
 		UpdatedAt time.Time `gorm:"column:updated_at;type:timestamp;default:CURRENT_TIMESTAMP;" faker:"-"`
+		// Soft delete
+		// https://gorm.io/docs/delete.html#Soft-Delete
+		DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"column:deleted_at;type:timestamp;index;" faker:"-"`
+		// Incremented on every update for optimistic concurrency control
+		Version int64 `json:"version" gorm:"column:version;type:bigint;default:1;" faker:"-"`
 	}
 )
 
-func NewTodoService(ctx context.Context) TodoService {
+func NewTodoService(ctx context.Context, repository Repository) TodoService {
 	t := &todoService{}
-	t.Repository = NewCloudSQL(ctx)
+	t.repository = repository
+	t.config = GetApplicationConfig(ctx)
 	return t
 }
 
-func (t *todoService) List(status bool, page, pagesize int, order string) (todos []*Todo, totalRows int, err error) {
-	resultOrm := t.Repository.DB().Model(&Todo{})
+// Offset pagination. Orders are limited to whitelisted columns,
+// updated_at DESC, id DESC is applied when no order is given, and id breaks the ties of the others.
+func (t *todoService) List(ctx context.Context, filter *TodoFilter, page, pagesize int, orders []SortOrder) (todos []*Todo, totalRows int, err error) {
+	sort, err := sortScope(orders)
+	if err != nil {
+		return nil, -1, xerrors.Errorf("List : %+w", err)
+	}
+
+	// Count the whole result set before the page is cut out of it
+	var count int64
+	if err = t.repository.CloudSQL().DB(ctx).Model(&Todo{}).Scopes(filter.Scope).Count(&count).Error; err != nil {
+		return nil, -1, xerrors.Errorf("List : can not count the records : %+w", err)
+	}
+
+	resultOrm := t.repository.CloudSQL().DB(ctx).Model(&Todo{}).Scopes(filter.Scope, sort)
 
 	if page > 0 {
 		offset := (page - 1) * pagesize
@@ -48,80 +85,539 @@ func (t *todoService) List(status bool, page, pagesize int, order string) (todos
 		resultOrm = resultOrm.Limit(pagesize)
 	}
 
-	if order != "" {
-		resultOrm = resultOrm.Order(order)
+	if err = resultOrm.Find(&todos).Error; err != nil {
+		return nil, -1, xerrors.Errorf("List : can not find the record : %+w", err)
 	}
 
-	resultOrm = resultOrm.Where("status = ?", status)
+	return todos, int(count), nil
+}
+
+// Keyset pagination ordered by updated_at DESC, id DESC.
+// Pass an empty cursor to fetch the first page. nextCursor is empty on the last page.
+func (t *todoService) ListByCursor(ctx context.Context, filter *TodoFilter, cursor string, pagesize int) (todos []*Todo, nextCursor string, err error) {
+	query, err := CursorQuery(filter, defaultTodoSort)
+	if err != nil {
+		return nil, "", xerrors.Errorf("ListByCursor : %+w", err)
+	}
+
+	resultOrm := t.repository.CloudSQL().DB(ctx).Model(&Todo{}).Scopes(filter.Scope)
+
+	if cursor != "" {
+		position, err := DecodeCursor(t.config.CursorSecret, cursor, query)
+		if err != nil {
+			return nil, "", xerrors.Errorf("ListByCursor : %+w", err)
+		}
+		resultOrm = resultOrm.Where("(updated_at < ? OR (updated_at = ? AND id < ?))",
+			position.UpdatedAt, position.UpdatedAt, position.ID)
+	}
+
+	// Fetch one extra row to find out if there is a next page
+	resultOrm = resultOrm.Order("updated_at DESC").Order("id DESC").Limit(pagesize + 1)
 
 	if err = resultOrm.Find(&todos).Error; err != nil {
-		return nil, -1, xerrors.Errorf("List : can not find the record : %+w", err)
+		return nil, "", xerrors.Errorf("ListByCursor : can not find the record : %+w", err)
+	}
+
+	if len(todos) <= pagesize {
+		return todos, "", nil
 	}
 
-	return todos, len(todos), nil
+	todos = todos[:pagesize]
+	last := todos[len(todos)-1]
+	nextCursor, err = EncodeCursor(t.config.CursorSecret, &Cursor{UpdatedAt: last.UpdatedAt, ID: last.ID, Query: query})
+	if err != nil {
+		return nil, "", xerrors.Errorf("ListByCursor : %+w", err)
+	}
+
+	return todos, nextCursor, nil
+}
+
+// Full-text search on task ranked by relevance.
+// Requires the FULLTEXT index todos_task_fulltext, a GIN index of the same name
+// on PostgreSQL, or todos_fts on SQLite.
+// https://dev.mysql.com/doc/refman/5.7/en/fulltext-search.html
+func (t *todoService) Search(ctx context.Context, query string, opts *SearchOptions) (results []*TodoSearchResult, totalRows int, err error) {
+	if opts == nil {
+		opts = &SearchOptions{}
+	}
+
+	terms := searchTerms(query, opts.Mode)
+	if len(terms) == 0 {
+		return nil, -1, xerrors.Errorf("Search : %+w", ErrInvalidSearch.Withf("query must not be empty"))
+	}
+
+	match, err := newFullTextMatch(t.repository.CloudSQL().Driver(), query, opts.Mode)
+	if err != nil {
+		return nil, -1, xerrors.Errorf("Search : %+w", err)
+	}
+
+	// Count the whole result set before the page is cut out of it
+	var count int64
+	if err = t.repository.CloudSQL().DB(ctx).Model(&Todo{}).
+		Scopes(opts.Filter.Scope).
+		Where(match.Where, match.Args...).
+		Count(&count).Error; err != nil {
+		return nil, -1, xerrors.Errorf("Search : can not count the records : %+w", err)
+	}
+
+	resultOrm := t.repository.CloudSQL().DB(ctx).Model(&Todo{}).
+		Select("*, "+match.Score+" AS score", match.Args...).
+		Scopes(opts.Filter.Scope).
+		Where(match.Where, match.Args...).
+		Order("score DESC").Order("id DESC")
+
+	if opts.Page > 0 {
+		resultOrm = resultOrm.Offset((opts.Page - 1) * opts.PageSize).Limit(opts.PageSize)
+	} else {
+		resultOrm = resultOrm.Limit(opts.PageSize)
+	}
+
+	if err = resultOrm.Find(&results).Error; err != nil {
+		return nil, -1, xerrors.Errorf("Search : can not find the record : %+w", err)
+	}
+
+	for _, result := range results {
+		result.Snippet = Snippet(result.Task, terms)
+	}
+
+	return results, int(count), nil
 }
 
 // Query
 // https://gorm.io/docs/query.html
 // https://gorm.io/docs/advanced_query.html
-func (t *todoService) Get(id int64) (*Todo, error) {
+func (t *todoService) Get(ctx context.Context, id int64) (*Todo, error) {
+	return getTodo(t.repository.CloudSQL().DB(ctx), id)
+}
+
+func getTodo(db *gorm.DB, id int64) (*Todo, error) {
+	todo := &Todo{}
+	if err := db.First(todo, id).Error; err != nil {
+		return nil, xerrors.Errorf("Get : %+w", todoNotFound(err))
+	}
+
+	return todo, nil
+}
+
+// The slug is a stable identifier, an update may only repeat it
+func checkSlugUnchanged(todo *Todo, orgTodo *Todo) error {
+	if todo.Slug != "" && todo.Slug != orgTodo.Slug {
+		return ErrInvalidParameter.Withf("slug can not be changed")
+	}
+	return nil
+}
+
+// Find by the slug generated by the database
+func (t *todoService) GetBySlug(ctx context.Context, slug string) (*Todo, error) {
 	todo := &Todo{}
-	if err := t.Repository.DB().First(todo, id).Error; err != nil {
-		return nil, xerrors.Errorf("Get : %+w", err)
+	if err := t.repository.CloudSQL().DB(ctx).Where("slug = ?", slug).Order("id").First(todo).Error; err != nil {
+		return nil, xerrors.Errorf("GetBySlug : %+w", todoNotFound(err))
 	}
 
 	return todo, nil
 }
 
 // Create
+// The stored row is returned since the before_insert_todos trigger overwrites the slug.
 // https://gorm.io/docs/create.html
-func (t *todoService) Create(todo *Todo) (*Todo, error) {
-	tx := t.Repository.DB().Save(todo)
+func (t *todoService) Create(ctx context.Context, todo *Todo) (*Todo, error) {
+	var created *Todo
+	err := t.repository.WithTx(ctx, func(tx Repository) (err error) {
+		// Save fills the ID in, a retry has to start from the original
+		row := *todo
+		created, err = createTodo(tx.CloudSQL().DB(ctx), &row)
+		return err
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("Create : %+w", err)
+	}
+
+	return created, nil
+}
+
+func createTodo(db *gorm.DB, todo *Todo) (*Todo, error) {
+	tx := db.Scopes(omitSlug).Save(todo)
 
 	if tx.Error != nil {
 		return nil, xerrors.Errorf("Create : %+w", tx.Error)
 	}
 
-	return todo, nil
+	// Reload the values generated by the database
+	return getTodo(db, todo.ID)
+}
+
+// The database generates the slug. PostgreSQL does it with the column default,
+// which only applies when the column is left out of the INSERT.
+// The triggers of MySQL and SQLite overwrite it anyway.
+func omitSlug(db *gorm.DB) *gorm.DB {
+	if db.Dialector.Name() == DB_DRIVER_POSTGRES {
+		return db.Omit("slug")
+	}
+	return db
 }
 
 // Create in Batches
+// The stored rows are returned in the same order.
 // https://gorm.io/docs/create.html
-func (t *todoService) CreateInBatches(todos []Todo) ([]Todo, error) {
-	tx := t.Repository.DB().CreateInBatches(todos, len(todos))
+func (t *todoService) CreateInBatches(ctx context.Context, todos []Todo) ([]Todo, error) {
+	tx := t.repository.CloudSQL().DB(ctx).Scopes(omitSlug).CreateInBatches(todos, len(todos))
 
 	if tx.Error != nil {
 		return nil, xerrors.Errorf("Create : %+w", tx.Error)
 	}
 
+	// Reload the values generated by the database
+	ids := make([]int64, 0, len(todos))
+	for _, todo := range todos {
+		ids = append(ids, todo.ID)
+	}
+
+	var stored []Todo
+	if err := t.repository.CloudSQL().DB(ctx).Where("id IN ?", ids).Order("id").Find(&stored).Error; err != nil {
+		return nil, xerrors.Errorf("Create : %+w", err)
+	}
+
+	byID := make(map[int64]Todo, len(stored))
+	for _, todo := range stored {
+		byID[todo.ID] = todo
+	}
+	for i := range todos {
+		todos[i] = byID[todos[i].ID]
+	}
+
 	return todos, nil
 }
 
-// Delete
+// Delete moves the record to the trash since Todo has DeletedAt
 // https://gorm.io/docs/delete.html
-func (t *todoService) Delete(ID int64) (rowsAffected int64, err error) {
-	todo := &Todo{}
-	tx := t.Repository.DB().First(todo, ID)
-	if tx.Error != nil {
-		return -1, xerrors.Errorf("Delete : can not find the record : %+w", tx.Error)
+func (t *todoService) Delete(ctx context.Context, ID int64) (rowsAffected int64, err error) {
+	err = t.repository.WithTx(ctx, func(tx Repository) error {
+		// Lock the row until it's deleted
+		todo := &Todo{}
+		result := tx.CloudSQL().DB(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(todo, ID)
+		if result.Error != nil {
+			return xerrors.Errorf("Delete : can not find the record : %+w", todoNotFound(result.Error))
+		}
+
+		result = tx.CloudSQL().DB(ctx).Delete(todo)
+		if result.Error != nil {
+			return xerrors.Errorf("Can not Delete : %+w", result.Error)
+		}
+
+		rowsAffected = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return -1, xerrors.Errorf("Delete : %+w", err)
 	}
 
-	tx = t.Repository.DB().Delete(todo)
+	return rowsAffected, nil
+}
+
+// Delete only when the version has not been changed since the caller read it
+func (t *todoService) DeleteVersion(ctx context.Context, ID int64, version int64) (rowsAffected int64, err error) {
+	return deleteTodoVersion(t.repository.CloudSQL().DB(ctx), ID, version)
+}
+
+func deleteTodoVersion(db *gorm.DB, ID int64, version int64) (rowsAffected int64, err error) {
+	tx := db.Where("id = ? AND version = ?", ID, version).Delete(&Todo{})
 	if tx.Error != nil {
 		return -1, xerrors.Errorf("Can not Delete : %+w", tx.Error)
 	}
 
+	if tx.RowsAffected == 0 {
+		return -1, versionError(db, "DeleteVersion", ID)
+	}
+
 	return tx.RowsAffected, nil
 }
 
-// Update
+// Trashed records, the most recently deleted first
+func (t *todoService) ListTrash(ctx context.Context, page, pagesize int) (todos []*Todo, totalRows int, err error) {
+	trashed := func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+
+	// Count the whole result set before the page is cut out of it
+	var count int64
+	if err = t.repository.CloudSQL().DB(ctx).Model(&Todo{}).Scopes(trashed).Count(&count).Error; err != nil {
+		return nil, -1, xerrors.Errorf("ListTrash : can not count the records : %+w", err)
+	}
+
+	resultOrm := t.repository.CloudSQL().DB(ctx).Model(&Todo{}).Scopes(trashed).Order("deleted_at DESC").Order("id DESC")
+
+	if page > 0 {
+		resultOrm = resultOrm.Offset((page - 1) * pagesize).Limit(pagesize)
+	} else {
+		resultOrm = resultOrm.Limit(pagesize)
+	}
+
+	if err = resultOrm.Find(&todos).Error; err != nil {
+		return nil, -1, xerrors.Errorf("ListTrash : can not find the record : %+w", err)
+	}
+
+	return todos, int(count), nil
+}
+
+// Take the record back from the trash
+func (t *todoService) Restore(ctx context.Context, ID int64) (*Todo, error) {
+	var restored *Todo
+	err := t.repository.WithTx(ctx, func(tx Repository) (err error) {
+		result := tx.CloudSQL().DB(ctx).Unscoped().Model(&Todo{}).
+			Where("id = ? AND deleted_at IS NOT NULL", ID).
+			Updates(map[string]interface{}{
+				"deleted_at": nil,
+				"version":    gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return xerrors.Errorf("Restore : %+w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return xerrors.Errorf("Restore : %+w", ErrTodoNotFound.Withf("todo %d is not in the trash", ID).Wrap(gorm.ErrRecordNotFound))
+		}
+
+		restored, err = getTodo(tx.CloudSQL().DB(ctx), ID)
+		return err
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("Restore : %+w", err)
+	}
+
+	return restored, nil
+}
+
+// Permanently delete records which have been in the trash longer than retention
+func (t *todoService) PurgeTrash(ctx context.Context, retention time.Duration) (rowsAffected int64, err error) {
+	threshold := time.Now().UTC().Add(-retention)
+
+	tx := t.repository.CloudSQL().DB(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at <= ?", threshold).
+		Delete(&Todo{})
+	if tx.Error != nil {
+		return -1, xerrors.Errorf("PurgeTrash : %+w", tx.Error)
+	}
+
+	return tx.RowsAffected, nil
+}
+
+// Update compares and swaps the version.
+// todo.Version must be the version which the caller has read,
+// ErrVersionConflict is returned when someone else has updated it in the meantime.
 // https://gorm.io/docs/update.html
-func (t *todoService) Update(todo *Todo) (*Todo, error) {
-	tx := t.Repository.DB().Save(todo)
+func (t *todoService) Update(ctx context.Context, todo *Todo) (*Todo, error) {
+	var updated *Todo
+	err := t.repository.WithTx(ctx, func(tx Repository) (err error) {
+		updated, err = updateTodo(tx.CloudSQL().DB(ctx), todo)
+		return err
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("Update : %+w", err)
+	}
+
+	return updated, nil
+}
 
+func updateTodo(db *gorm.DB, todo *Todo) (*Todo, error) {
+	tx := db.Model(&Todo{}).
+		Where("id = ? AND version = ?", todo.ID, todo.Version).
+		Updates(map[string]interface{}{
+			"task":       todo.Task,
+			"status":     todo.Status,
+			"created_at": todo.CreatedAt,
+			"updated_at": todo.UpdatedAt,
+			"version":    gorm.Expr("version + 1"),
+		})
 	if tx.Error != nil {
 		return nil, xerrors.Errorf("Update : %+w", tx.Error)
 	}
 
-	return todo, nil
+	if tx.RowsAffected == 0 {
+		return nil, versionError(db, "Update", todo.ID)
+	}
+
+	return getTodo(db, todo.ID)
+}
+
+// Tell not found from version conflict after a compare and swap failed
+func versionError(db *gorm.DB, operation string, ID int64) error {
+	if err := db.First(&Todo{}, ID).Error; err != nil {
+		return xerrors.Errorf("%s : can not find the record : %+w", operation, todoNotFound(err))
+	}
+	return xerrors.Errorf("%s : id %d : %+w", operation, ID, ErrVersionConflict)
+}
+
+// Patch updates only the given fields, comparing and swapping the version like Update
+func (t *todoService) Patch(ctx context.Context, ID int64, version int64, patch *TodoPatch) (*Todo, error) {
+	if err := patch.Validate(); err != nil {
+		return nil, xerrors.Errorf("Patch : %+w", err)
+	}
+
+	// Nothing to write
+	if patch.IsEmpty() {
+		return t.Get(ctx, ID)
+	}
+
+	values := map[string]interface{}{
+		"updated_at": time.Time.UTC(time.Now()),
+		"version":    gorm.Expr("version + 1"),
+	}
+	if patch.Task != nil {
+		values["task"] = *patch.Task
+	}
+	if patch.Status != nil {
+		values["status"] = *patch.Status
+	}
+
+	var patched *Todo
+	err := t.repository.WithTx(ctx, func(tx Repository) (err error) {
+		result := tx.CloudSQL().DB(ctx).Model(&Todo{}).
+			Where("id = ? AND version = ?", ID, version).
+			Updates(values)
+		if result.Error != nil {
+			return xerrors.Errorf("Patch : %+w", result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			return versionError(tx.CloudSQL().DB(ctx), "Patch", ID)
+		}
+
+		patched, err = getTodo(tx.CloudSQL().DB(ctx), ID)
+		return err
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("Patch : %+w", err)
+	}
+
+	return patched, nil
+}
+
+// Create every todo in a single transaction
+func (t *todoService) BatchCreate(ctx context.Context, todos []*Todo, mode string) ([]*BatchResult, error) {
+	return t.runBatch(ctx, len(todos), mode, func(tx *gorm.DB, i int) (*BatchResult, error) {
+		// Save fills the ID in, a retry has to start from the original
+		row := *todos[i]
+		todo, err := createTodo(tx, &row)
+		if err != nil {
+			return nil, xerrors.Errorf("BatchCreate : %+w", err)
+		}
+		return &BatchResult{ID: todo.ID, Todo: todo}, nil
+	})
+}
+
+// Update every todo in a single transaction.
+// Zero Version updates the todo regardless of its current version.
+func (t *todoService) BatchUpdate(ctx context.Context, todos []*Todo, mode string) ([]*BatchResult, error) {
+	return t.runBatch(ctx, len(todos), mode, func(tx *gorm.DB, i int) (*BatchResult, error) {
+		orgTodo, err := getTodo(tx, todos[i].ID)
+		if err != nil {
+			return nil, xerrors.Errorf("BatchUpdate : %+w", err)
+		}
+		if err := checkSlugUnchanged(todos[i], orgTodo); err != nil {
+			return nil, xerrors.Errorf("BatchUpdate : %+w", err)
+		}
+
+		version := todos[i].Version
+		if version == 0 {
+			version = orgTodo.Version
+		}
+
+		todo, err := updateTodo(tx, &Todo{
+			ID:        orgTodo.ID,
+			Slug:      orgTodo.Slug,
+			Task:      todos[i].Task,
+			Status:    todos[i].Status,
+			UpdatedAt: time.Time.UTC(time.Now()),
+			CreatedAt: orgTodo.CreatedAt,
+			Version:   version,
+		})
+		if err != nil {
+			return nil, xerrors.Errorf("BatchUpdate : %+w", err)
+		}
+		return &BatchResult{ID: todo.ID, Todo: todo}, nil
+	})
+}
+
+// Delete every todo in a single transaction.
+// Zero Version deletes the todo regardless of its current version.
+func (t *todoService) BatchDelete(ctx context.Context, items []*BatchDeleteItem, mode string) ([]*BatchResult, error) {
+	return t.runBatch(ctx, len(items), mode, func(tx *gorm.DB, i int) (*BatchResult, error) {
+		version := items[i].Version
+		if version == 0 {
+			orgTodo, err := getTodo(tx, items[i].ID)
+			if err != nil {
+				return nil, xerrors.Errorf("BatchDelete : %+w", err)
+			}
+			version = orgTodo.Version
+		}
+
+		if _, err := deleteTodoVersion(tx, items[i].ID, version); err != nil {
+			return nil, xerrors.Errorf("BatchDelete : %+w", err)
+		}
+		return &BatchResult{ID: items[i].ID}, nil
+	})
+}
+
+// Run fn for every item inside one transaction.
+// BatchModeAtomic rolls back everything on the first failure,
+// BatchModePartial isolates each item with a savepoint and commits the succeeded ones.
+func (t *todoService) runBatch(ctx context.Context, size int, mode string, fn func(tx *gorm.DB, i int) (*BatchResult, error)) ([]*BatchResult, error) {
+	if mode == "" {
+		mode = BatchModeAtomic
+	}
+	if mode != BatchModeAtomic && mode != BatchModePartial {
+		return nil, xerrors.Errorf("Batch : %+w", ErrInvalidBatch.Withf("unknown batch mode %s", mode))
+	}
+
+	var results []*BatchResult
+	run := func(tx Repository, i int) *BatchResult {
+		result, err := fn(tx.CloudSQL().DB(ctx), i)
+		if err != nil {
+			result = &BatchResult{Err: err}
+		}
+		result.Index = i
+		return result
+	}
+
+	err := t.repository.WithTx(ctx, func(tx Repository) error {
+		// Start over on retry
+		results = make([]*BatchResult, size)
+
+		for i := 0; i < size; i++ {
+			if mode == BatchModeAtomic {
+				results[i] = run(tx, i)
+				if results[i].Err != nil {
+					return results[i].Err
+				}
+				continue
+			}
+
+			// Nested unit of work is a savepoint
+			_ = tx.WithTx(ctx, func(sp Repository) error {
+				results[i] = run(sp, i)
+				return results[i].Err
+			})
+
+			// A deadlock has rolled back the whole transaction
+			if isRetryableTxError(results[i].Err) {
+				return results[i].Err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		// Nothing has been written
+		for i := range results {
+			if results[i] == nil {
+				results[i] = &BatchResult{Index: i}
+			}
+			if results[i].Err == nil {
+				results[i].Err = xerrors.Errorf("Batch : %+w", ErrBatchRolledBack)
+				results[i].Todo = nil
+			}
+		}
+		return results, xerrors.Errorf("Batch : %+w", err)
+	}
+
+	return results, nil
 }
@@ -0,0 +1,702 @@
+package main
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+var (
+	memoryStore     *memoryTodoStore
+	memoryStoreOnce sync.Once
+
+	// InnoDB default stopwords, never hit in full-text search
+	// https://dev.mysql.com/doc/refman/5.7/en/fulltext-stopwords.html
+	fullTextStopwords = map[string]bool{
+		"a": true, "about": true, "an": true, "are": true, "as": true, "at": true,
+		"be": true, "by": true, "com": true, "de": true, "en": true, "for": true,
+		"from": true, "how": true, "i": true, "in": true, "is": true, "it": true,
+		"la": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+		"this": true, "to": true, "was": true, "what": true, "when": true, "where": true,
+		"who": true, "will": true, "with": true, "und": true, "www": true,
+	}
+)
+
+const (
+	// innodb_ft_min_token_size, shorter words are not indexed
+	fullTextMinTokenSize = 3
+)
+
+type (
+	// Rows of the todos table by id, including the trashed ones
+	memoryTodoTable map[int64]*Todo
+
+	// Todos kept in the process. Shared by every memoryTodoService like a database.
+	memoryTodoStore struct {
+		mu    sync.Mutex
+		todos memoryTodoTable
+		// AUTO_INCREMENT, which is not rolled back with the transaction
+		lastID int64
+	}
+
+	// TodoService without any database. Behaves like todoService on MySQL,
+	// including slug generation, ordering, pagination and not-found errors.
+	memoryTodoService struct {
+		store  *memoryTodoStore
+		config *applicationConfig
+	}
+)
+
+// The store shared by the process
+func GetMemoryTodoStore() *memoryTodoStore {
+	memoryStoreOnce.Do(func() {
+		memoryStore = newMemoryTodoStore()
+	})
+	return memoryStore
+}
+
+func newMemoryTodoStore() *memoryTodoStore {
+	return &memoryTodoStore{todos: memoryTodoTable{}}
+}
+
+// Drop every todo and start ids over, like recreating the table
+func (s *memoryTodoStore) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.todos = memoryTodoTable{}
+	s.lastID = 0
+}
+
+func NewMemoryTodoService(ctx context.Context, store *memoryTodoStore) TodoService {
+	t := &memoryTodoService{}
+	t.store = store
+	t.config = GetApplicationConfig(ctx)
+	return t
+}
+
+func (t *memoryTodoService) List(ctx context.Context, filter *TodoFilter, page, pagesize int, orders []SortOrder) (todos []*Todo, totalRows int, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, -1, xerrors.Errorf("List : %+w", err)
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	todos = t.store.todos.find(filter.Match)
+	if err := sortTodos(todos, orders); err != nil {
+		return nil, -1, xerrors.Errorf("List : %+w", err)
+	}
+
+	return pageOf(todos, page, pagesize), len(todos), nil
+}
+
+func (t *memoryTodoService) ListByCursor(ctx context.Context, filter *TodoFilter, cursor string, pagesize int) (todos []*Todo, nextCursor string, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", xerrors.Errorf("ListByCursor : %+w", err)
+	}
+
+	query, err := CursorQuery(filter, defaultTodoSort)
+	if err != nil {
+		return nil, "", xerrors.Errorf("ListByCursor : %+w", err)
+	}
+
+	match := filter.Match
+	if cursor != "" {
+		position, err := DecodeCursor(t.config.CursorSecret, cursor, query)
+		if err != nil {
+			return nil, "", xerrors.Errorf("ListByCursor : %+w", err)
+		}
+		match = func(todo *Todo) bool {
+			return filter.Match(todo) && (todo.UpdatedAt.Before(position.UpdatedAt) ||
+				(todo.UpdatedAt.Equal(position.UpdatedAt) && todo.ID < position.ID))
+		}
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	todos = t.store.todos.find(match)
+	if err := sortTodos(todos, defaultTodoSort); err != nil {
+		return nil, "", xerrors.Errorf("ListByCursor : %+w", err)
+	}
+
+	if len(todos) <= pagesize {
+		return todos, "", nil
+	}
+
+	todos = todos[:pagesize]
+	last := todos[len(todos)-1]
+	nextCursor, err = EncodeCursor(t.config.CursorSecret, &Cursor{UpdatedAt: last.UpdatedAt, ID: last.ID, Query: query})
+	if err != nil {
+		return nil, "", xerrors.Errorf("ListByCursor : %+w", err)
+	}
+
+	return todos, nextCursor, nil
+}
+
+// Full-text search emulating InnoDB. Scores are the number of hits
+// rather than the BM25 relevance of MySQL, but rank rows the same way for simple queries.
+func (t *memoryTodoService) Search(ctx context.Context, query string, opts *SearchOptions) (results []*TodoSearchResult, totalRows int, err error) {
+	if opts == nil {
+		opts = &SearchOptions{}
+	}
+
+	terms := searchTerms(query, opts.Mode)
+	if len(terms) == 0 {
+		return nil, -1, xerrors.Errorf("Search : %+w", ErrInvalidSearch.Withf("query must not be empty"))
+	}
+
+	if _, err := searchModifier(opts.Mode); err != nil {
+		return nil, -1, xerrors.Errorf("Search : %+w", err)
+	}
+	score := naturalScore
+	if opts.Mode == SearchModeBoolean {
+		score = booleanScore
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, -1, xerrors.Errorf("Search : %+w", err)
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	for _, todo := range t.store.todos.find(opts.Filter.Match) {
+		if s := score(query, todo.Task); 0 < s {
+			results = append(results, &TodoSearchResult{Todo: *todo, Score: s})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[j].Score < results[i].Score
+		}
+		return results[j].ID < results[i].ID
+	})
+
+	totalRows = len(results)
+	offset := 0
+	if opts.Page > 0 {
+		offset = (opts.Page - 1) * opts.PageSize
+	}
+	start, end := pageBounds(len(results), offset, opts.PageSize)
+	results = results[start:end]
+
+	for _, result := range results {
+		result.Snippet = Snippet(result.Task, terms)
+	}
+
+	return results, totalRows, nil
+}
+
+func (t *memoryTodoService) Get(ctx context.Context, id int64) (*Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.Errorf("Get : %+w", err)
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	todo, err := t.store.todos.get(id)
+	if err != nil {
+		return nil, xerrors.Errorf("Get : %+w", err)
+	}
+	return copyTodo(todo), nil
+}
+
+// The oldest todo with the slug, compared case-insensitively like MySQL
+func (t *memoryTodoService) GetBySlug(ctx context.Context, slug string) (*Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.Errorf("GetBySlug : %+w", err)
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	todos := t.store.todos.find(func(todo *Todo) bool {
+		return strings.EqualFold(todo.Slug, slug)
+	})
+	if len(todos) == 0 {
+		return nil, xerrors.Errorf("GetBySlug : %+w", todoNotFound(gorm.ErrRecordNotFound))
+	}
+	return todos[0], nil
+}
+
+func (t *memoryTodoService) Create(ctx context.Context, todo *Todo) (*Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.Errorf("Create : %+w", err)
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	return copyTodo(t.store.insert(t.store.todos, todo)), nil
+}
+
+// The stored todos are returned in the same order
+func (t *memoryTodoService) CreateInBatches(ctx context.Context, todos []Todo) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.Errorf("Create : %+w", err)
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	for i := range todos {
+		todos[i] = *t.store.insert(t.store.todos, &todos[i])
+	}
+	return todos, nil
+}
+
+func (t *memoryTodoService) Delete(ctx context.Context, ID int64) (rowsAffected int64, err error) {
+	if err := ctx.Err(); err != nil {
+		return -1, xerrors.Errorf("Delete : %+w", err)
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	todo, err := t.store.todos.get(ID)
+	if err != nil {
+		return -1, xerrors.Errorf("Delete : can not find the record : %+w", err)
+	}
+
+	todo.DeletedAt = gorm.DeletedAt{Time: timestampOf(time.Now()), Valid: true}
+	return 1, nil
+}
+
+func (t *memoryTodoService) DeleteVersion(ctx context.Context, ID int64, version int64) (rowsAffected int64, err error) {
+	if err := ctx.Err(); err != nil {
+		return -1, xerrors.Errorf("DeleteVersion : %+w", err)
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	return t.store.todos.deleteVersion(ID, version)
+}
+
+// Trashed todos, the most recently deleted first
+func (t *memoryTodoService) ListTrash(ctx context.Context, page, pagesize int) (todos []*Todo, totalRows int, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, -1, xerrors.Errorf("ListTrash : %+w", err)
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	for _, todo := range t.store.todos.sorted() {
+		if todo.DeletedAt.Valid {
+			todos = append(todos, copyTodo(todo))
+		}
+	}
+	sort.SliceStable(todos, func(i, j int) bool {
+		if !todos[i].DeletedAt.Time.Equal(todos[j].DeletedAt.Time) {
+			return todos[j].DeletedAt.Time.Before(todos[i].DeletedAt.Time)
+		}
+		return todos[j].ID < todos[i].ID
+	})
+
+	return pageOf(todos, page, pagesize), len(todos), nil
+}
+
+func (t *memoryTodoService) Restore(ctx context.Context, ID int64) (*Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.Errorf("Restore : %+w", err)
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	todo, ok := t.store.todos[ID]
+	if !ok || !todo.DeletedAt.Valid {
+		return nil, xerrors.Errorf("Restore : %+w", ErrTodoNotFound.Withf("todo %d is not in the trash", ID).Wrap(gorm.ErrRecordNotFound))
+	}
+
+	todo.DeletedAt = gorm.DeletedAt{}
+	todo.UpdatedAt = timestampOf(time.Now())
+	todo.Version++
+	return copyTodo(todo), nil
+}
+
+func (t *memoryTodoService) PurgeTrash(ctx context.Context, retention time.Duration) (rowsAffected int64, err error) {
+	if err := ctx.Err(); err != nil {
+		return -1, xerrors.Errorf("PurgeTrash : %+w", err)
+	}
+
+	threshold := time.Now().UTC().Add(-retention)
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	for ID, todo := range t.store.todos {
+		if todo.DeletedAt.Valid && !todo.DeletedAt.Time.After(threshold) {
+			delete(t.store.todos, ID)
+			rowsAffected++
+		}
+	}
+	return rowsAffected, nil
+}
+
+func (t *memoryTodoService) Update(ctx context.Context, todo *Todo) (*Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.Errorf("Update : %+w", err)
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	updated, err := t.store.todos.update(todo)
+	if err != nil {
+		return nil, xerrors.Errorf("Update : %+w", err)
+	}
+	return updated, nil
+}
+
+func (t *memoryTodoService) Patch(ctx context.Context, ID int64, version int64, patch *TodoPatch) (*Todo, error) {
+	if err := patch.Validate(); err != nil {
+		return nil, xerrors.Errorf("Patch : %+w", err)
+	}
+
+	// Nothing to write
+	if patch.IsEmpty() {
+		return t.Get(ctx, ID)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.Errorf("Patch : %+w", err)
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	todo, ok := t.store.todos[ID]
+	if !ok || todo.DeletedAt.Valid || todo.Version != version {
+		return nil, xerrors.Errorf("Patch : %+w", t.store.todos.versionError("Patch", ID))
+	}
+
+	if patch.Task != nil {
+		todo.Task = *patch.Task
+	}
+	if patch.Status != nil {
+		todo.Status = *patch.Status
+	}
+	todo.UpdatedAt = timestampOf(time.Now())
+	todo.Version++
+
+	return copyTodo(todo), nil
+}
+
+func (t *memoryTodoService) BatchCreate(ctx context.Context, todos []*Todo, mode string) ([]*BatchResult, error) {
+	return t.runBatch(ctx, len(todos), mode, func(tx memoryTodoTable, i int) (*BatchResult, error) {
+		todo := copyTodo(t.store.insert(tx, todos[i]))
+		return &BatchResult{ID: todo.ID, Todo: todo}, nil
+	})
+}
+
+// Zero Version updates the todo regardless of its current version
+func (t *memoryTodoService) BatchUpdate(ctx context.Context, todos []*Todo, mode string) ([]*BatchResult, error) {
+	return t.runBatch(ctx, len(todos), mode, func(tx memoryTodoTable, i int) (*BatchResult, error) {
+		orgTodo, err := tx.get(todos[i].ID)
+		if err != nil {
+			return nil, xerrors.Errorf("BatchUpdate : %+w", err)
+		}
+		if err := checkSlugUnchanged(todos[i], orgTodo); err != nil {
+			return nil, xerrors.Errorf("BatchUpdate : %+w", err)
+		}
+
+		version := todos[i].Version
+		if version == 0 {
+			version = orgTodo.Version
+		}
+
+		todo, err := tx.update(&Todo{
+			ID:        orgTodo.ID,
+			Slug:      orgTodo.Slug,
+			Task:      todos[i].Task,
+			Status:    todos[i].Status,
+			UpdatedAt: time.Time.UTC(time.Now()),
+			CreatedAt: orgTodo.CreatedAt,
+			Version:   version,
+		})
+		if err != nil {
+			return nil, xerrors.Errorf("BatchUpdate : %+w", err)
+		}
+		return &BatchResult{ID: todo.ID, Todo: todo}, nil
+	})
+}
+
+// Zero Version deletes the todo regardless of its current version
+func (t *memoryTodoService) BatchDelete(ctx context.Context, items []*BatchDeleteItem, mode string) ([]*BatchResult, error) {
+	return t.runBatch(ctx, len(items), mode, func(tx memoryTodoTable, i int) (*BatchResult, error) {
+		version := items[i].Version
+		if version == 0 {
+			orgTodo, err := tx.get(items[i].ID)
+			if err != nil {
+				return nil, xerrors.Errorf("BatchDelete : %+w", err)
+			}
+			version = orgTodo.Version
+		}
+
+		if _, err := tx.deleteVersion(items[i].ID, version); err != nil {
+			return nil, xerrors.Errorf("BatchDelete : %+w", err)
+		}
+		return &BatchResult{ID: items[i].ID}, nil
+	})
+}
+
+// Run fn for every item on a copy of the table, which replaces the table when the batch succeeds.
+// BatchModePartial runs each item on another copy, which is kept only when the item succeeds.
+func (t *memoryTodoService) runBatch(ctx context.Context, size int, mode string, fn func(tx memoryTodoTable, i int) (*BatchResult, error)) ([]*BatchResult, error) {
+	if mode == "" {
+		mode = BatchModeAtomic
+	}
+	if mode != BatchModeAtomic && mode != BatchModePartial {
+		return nil, xerrors.Errorf("Batch : %+w", ErrInvalidBatch.Withf("unknown batch mode %s", mode))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, xerrors.Errorf("Batch : %+w", err)
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	results := make([]*BatchResult, size)
+	run := func(tx memoryTodoTable, i int) *BatchResult {
+		result, err := fn(tx, i)
+		if err != nil {
+			result = &BatchResult{Err: err}
+		}
+		result.Index = i
+		return result
+	}
+
+	tx := t.store.todos.clone()
+	for i := 0; i < size; i++ {
+		if mode == BatchModeAtomic {
+			results[i] = run(tx, i)
+			if results[i].Err == nil {
+				continue
+			}
+
+			// Nothing has been written
+			for j := range results {
+				if results[j] == nil {
+					results[j] = &BatchResult{Index: j}
+				}
+				if results[j].Err == nil {
+					results[j].Err = xerrors.Errorf("Batch : %+w", ErrBatchRolledBack)
+					results[j].Todo = nil
+				}
+			}
+			return results, xerrors.Errorf("Batch : %+w", results[i].Err)
+		}
+
+		// Savepoint
+		sp := tx.clone()
+		if results[i] = run(sp, i); results[i].Err == nil {
+			tx = sp
+		}
+	}
+
+	t.store.todos = tx
+	return results, nil
+}
+
+// Insert the todo with a new id like the before_insert_todos trigger and the column defaults do
+func (s *memoryTodoStore) insert(table memoryTodoTable, todo *Todo) *Todo {
+	s.lastID++
+	now := timestampOf(time.Now())
+
+	row := &Todo{
+		ID:        s.lastID,
+		Slug:      uuid.NewString(),
+		Task:      todo.Task,
+		Status:    todo.Status,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Version:   1,
+	}
+	if !todo.CreatedAt.IsZero() {
+		row.CreatedAt = timestampOf(todo.CreatedAt)
+	}
+	if !todo.UpdatedAt.IsZero() {
+		row.UpdatedAt = timestampOf(todo.UpdatedAt)
+	}
+
+	table[row.ID] = row
+	return row
+}
+
+func (m memoryTodoTable) clone() memoryTodoTable {
+	c := make(memoryTodoTable, len(m))
+	for ID, todo := range m {
+		c[ID] = copyTodo(todo)
+	}
+	return c
+}
+
+// Every row ordered by id
+func (m memoryTodoTable) sorted() []*Todo {
+	todos := make([]*Todo, 0, len(m))
+	for _, todo := range m {
+		todos = append(todos, todo)
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		return todos[i].ID < todos[j].ID
+	})
+	return todos
+}
+
+// Copies of the todos which are not trashed and match, ordered by id
+func (m memoryTodoTable) find(match func(todo *Todo) bool) []*Todo {
+	var todos []*Todo
+	for _, todo := range m.sorted() {
+		if !todo.DeletedAt.Valid && match(todo) {
+			todos = append(todos, copyTodo(todo))
+		}
+	}
+	return todos
+}
+
+// The row of the todo which is not trashed
+func (m memoryTodoTable) get(ID int64) (*Todo, error) {
+	todo, ok := m[ID]
+	if !ok || todo.DeletedAt.Valid {
+		return nil, xerrors.Errorf("Get : %+w", todoNotFound(gorm.ErrRecordNotFound))
+	}
+	return todo, nil
+}
+
+// Compare and swap the version like updateTodo
+func (m memoryTodoTable) update(todo *Todo) (*Todo, error) {
+	row, ok := m[todo.ID]
+	if !ok || row.DeletedAt.Valid || row.Version != todo.Version {
+		return nil, m.versionError("Update", todo.ID)
+	}
+
+	row.Task = todo.Task
+	row.Status = todo.Status
+	row.CreatedAt = timestampOf(todo.CreatedAt)
+	row.UpdatedAt = timestampOf(todo.UpdatedAt)
+	row.Version++
+
+	return copyTodo(row), nil
+}
+
+func (m memoryTodoTable) deleteVersion(ID int64, version int64) (rowsAffected int64, err error) {
+	todo, ok := m[ID]
+	if !ok || todo.DeletedAt.Valid || todo.Version != version {
+		return -1, m.versionError("DeleteVersion", ID)
+	}
+
+	todo.DeletedAt = gorm.DeletedAt{Time: timestampOf(time.Now()), Valid: true}
+	return 1, nil
+}
+
+// Tell not found from version conflict like versionError
+func (m memoryTodoTable) versionError(operation string, ID int64) error {
+	if _, err := m.get(ID); err != nil {
+		return xerrors.Errorf("%s : can not find the record : %+w", operation, err)
+	}
+	return xerrors.Errorf("%s : id %d : %+w", operation, ID, ErrVersionConflict)
+}
+
+func copyTodo(todo *Todo) *Todo {
+	c := *todo
+	return &c
+}
+
+// TIMESTAMP columns keep whole seconds in UTC
+func timestampOf(t time.Time) time.Time {
+	return t.UTC().Round(time.Second)
+}
+
+// Cut out the page like OFFSET and LIMIT
+func pageOf(todos []*Todo, page, pagesize int) []*Todo {
+	offset := 0
+	if page > 0 {
+		offset = (page - 1) * pagesize
+	}
+	start, end := pageBounds(len(todos), offset, pagesize)
+	return todos[start:end]
+}
+
+// Bounds of OFFSET and LIMIT over size rows. Non positive limit means no limit like GORM.
+func pageBounds(size, offset, limit int) (start, end int) {
+	if offset < 0 || size <= offset {
+		return size, size
+	}
+	if 0 < limit && offset+limit < size {
+		return offset, offset + limit
+	}
+	return offset, size
+}
+
+// Words as the InnoDB full-text parser splits them, in lower case
+func fullTextWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// Words which are indexed, the others never hit
+func isFullTextToken(word string) bool {
+	return fullTextMinTokenSize <= len([]rune(word)) && !fullTextStopwords[word]
+}
+
+// Hits of every query word in the text
+func naturalScore(query string, text string) float64 {
+	hits := 0
+	words := fullTextWords(text)
+	for _, term := range fullTextWords(query) {
+		if !isFullTextToken(term) {
+			continue
+		}
+		for _, word := range words {
+			if word == term {
+				hits++
+			}
+		}
+	}
+	return float64(hits)
+}
+
+// Hits of the boolean mode query, zero unless every +word hits and no -word hits.
+// Trailing * matches the prefix.
+func booleanScore(query string, text string) float64 {
+	words := fullTextWords(text)
+	hit := func(term string, prefix bool) bool {
+		for _, word := range words {
+			if word == term || (prefix && strings.HasPrefix(word, term)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	hits := 0
+	for _, field := range strings.Fields(strings.ToLower(query)) {
+		operator, prefix, term := parseSearchWord(field, SearchModeBoolean)
+		if !isFullTextToken(term) {
+			continue
+		}
+
+		switch {
+		case operator == '-' && hit(term, prefix):
+			return 0
+		case operator == '+' && !hit(term, prefix):
+			return 0
+		case operator != '-' && hit(term, prefix):
+			hits++
+		}
+	}
+	return float64(hits)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Runs without Docker regardless of TODO_STORE
+func TestMemoryTodoService(t *testing.T) {
+	t.Helper()
+
+	ctx := context.Background()
+	store := newMemoryTodoStore()
+	todoService := NewMemoryTodoService(ctx, store)
+
+	wrapper := func(fn func(t *testing.T)) func(t *testing.T) {
+		return func(t *testing.T) {
+			store.Reset()
+			fn(t)
+			store.Reset()
+		}
+	}
+
+	todoServiceContract(t, todoService, wrapper)
+
+	t.Run("Returns copies", wrapper(func(t *testing.T) {
+		createdTodo, err := todoService.Create(ctx, &Todo{Task: "test task"})
+		assert.Nil(t, err)
+
+		createdTodo.Task = "changed"
+		found, err := todoService.Get(ctx, createdTodo.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, "test task", found.Task)
+	}))
+
+	t.Run("Atomic batch leaves the store untouched", wrapper(func(t *testing.T) {
+		createdTodo, err := todoService.Create(ctx, &Todo{Task: "test task"})
+		assert.Nil(t, err)
+
+		_, err = todoService.BatchUpdate(ctx, []*Todo{
+			{ID: createdTodo.ID, Task: "changed"},
+			{ID: 9999, Task: "missing"},
+		}, BatchModeAtomic)
+		assert.NotNil(t, err)
+
+		found, err := todoService.Get(ctx, createdTodo.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, "test task", found.Task)
+		assert.Equal(t, createdTodo.Version, found.Version)
+	}))
+
+	t.Run("Restore touches UpdatedAt like the SQL store", wrapper(func(t *testing.T) {
+		createdTodo, err := todoService.Create(ctx, &Todo{Task: "test task"})
+		assert.Nil(t, err)
+		_, err = todoService.Delete(ctx, createdTodo.ID)
+		assert.Nil(t, err)
+
+		yesterday := timestampOf(time.Now().Add(-24 * time.Hour))
+		store.todos[createdTodo.ID].UpdatedAt = yesterday
+
+		restored, err := todoService.Restore(ctx, createdTodo.ID)
+		assert.Nil(t, err)
+		assert.True(t, restored.UpdatedAt.After(yesterday))
+	}))
+
+	t.Run("Full-text search", func(t *testing.T) {
+		// Stopwords and short words never hit
+		assert.Equal(t, float64(0), naturalScore("the at", "walk the dog at noon"))
+		assert.Equal(t, float64(2), naturalScore("milk", "milk and milk tea"))
+		assert.Equal(t, float64(1), naturalScore("MILK", "buy milk"))
+
+		assert.Equal(t, float64(1), booleanScore("+milk -tea", "buy milk"))
+		assert.Equal(t, float64(0), booleanScore("+milk -tea", "milk tea"))
+		assert.Equal(t, float64(0), booleanScore("+milk", "buy bread"))
+		assert.Equal(t, float64(1), booleanScore("mil*", "buy milk"))
+	})
+}
@@ -6,46 +6,239 @@ import (
 	"fmt"
 	"github.com/bxcodec/faker/v3"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+	"gorm.io/gorm"
 	"math/rand"
+	"sort"
 	"testing"
 	"time"
 )
 
+// Runs against the store selected by TODO_STORE
 func TestTodoService(t *testing.T) {
 	t.Helper()
 
 	ctx := context.Background()
-	var todoService = NewTodoService(ctx)
+	todoServiceContract(t, newTestTodoService(t, ctx), eachTestWrapper)
+}
+
+// Behavior every TodoService has to share with the MySQL one.
+// wrapper runs each test on empty todos.
+func todoServiceContract(t *testing.T, todoService TodoService, wrapper func(fn func(t *testing.T)) func(t *testing.T)) {
+	t.Helper()
+
+	ctx := context.Background()
+	status := true
+	updatedAtAsc := []SortOrder{{Column: "updated_at"}}
 
-	t.Run("Create and Delete", eachTestWrapper(func(t *testing.T) {
+	t.Run("Create and Delete", wrapper(func(t *testing.T) {
 
 		todo := &Todo{
 			Task:   "test task",
 			Status: false,
 		}
-		createdTodo, err := todoService.Create(todo)
+		createdTodo, err := todoService.Create(ctx, todo)
 
 		assert.Nil(t, err)
 		assert.NotNil(t, createdTodo)
 		fmt.Printf("data %+v", createdTodo)
 
 		ID := createdTodo.ID
-		_, err = todoService.Get(ID)
+		_, err = todoService.Get(ctx, ID)
 		assert.Nil(t, err)
 		// Should be no data stored in the database.
 		assert.Error(t, errors.New("record not found"))
 
-		ids, err := todoService.Delete(createdTodo.ID)
+		ids, err := todoService.Delete(ctx, createdTodo.ID)
 		assert.Nil(t, err)
 		assert.Equal(t, ids, int64(1))
 
-		getTodo, err := todoService.Get(ID)
+		getTodo, err := todoService.Get(ctx, ID)
 		assert.NotNil(t, err)
 		assert.Nil(t, getTodo)
 
 	}))
 
-	t.Run("List and CreateInBatches", eachTestWrapper(func(t *testing.T) {
+	t.Run("Canceled context", wrapper(func(t *testing.T) {
+		createdTodo, err := todoService.Create(ctx, &Todo{Task: "test task"})
+		assert.Nil(t, err)
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		// The query never reaches the store
+		_, err = todoService.Get(canceled, createdTodo.ID)
+		assert.True(t, xerrors.Is(err, context.Canceled))
+		assert.False(t, xerrors.Is(err, ErrTodoNotFound))
+
+		_, err = todoService.Create(canceled, &Todo{Task: "never stored"})
+		assert.True(t, xerrors.Is(err, context.Canceled))
+
+		_, rows, err := todoService.List(ctx, nil, 1, 10, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, rows)
+	}))
+
+	t.Run("Create returns the generated slug", wrapper(func(t *testing.T) {
+		createdTodo, err := todoService.Create(ctx, &Todo{Slug: "client-slug", Task: "test task"})
+		assert.Nil(t, err)
+		// Overwritten by the before_insert_todos trigger
+		assert.NotEqual(t, "client-slug", createdTodo.Slug)
+		assert.NotEmpty(t, createdTodo.Slug)
+
+		found, err := todoService.GetBySlug(ctx, createdTodo.Slug)
+		assert.Nil(t, err)
+		assert.Equal(t, createdTodo.ID, found.ID)
+
+		_, err = todoService.GetBySlug(ctx, "client-slug")
+		assert.True(t, xerrors.Is(err, gorm.ErrRecordNotFound))
+		assert.True(t, xerrors.Is(err, ErrTodoNotFound))
+
+		createdTodos, err := todoService.CreateInBatches(ctx, []Todo{
+			{Slug: "client-slug", Task: "first"},
+			{Slug: "client-slug", Task: "second"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "first", createdTodos[0].Task)
+		assert.Equal(t, "second", createdTodos[1].Task)
+		assert.NotEqual(t, "client-slug", createdTodos[0].Slug)
+		assert.NotEqual(t, createdTodos[0].Slug, createdTodos[1].Slug)
+	}))
+
+	t.Run("Trash, Restore and Purge", wrapper(func(t *testing.T) {
+		createdTodo, err := todoService.Create(ctx, &Todo{Task: "test task"})
+		assert.Nil(t, err)
+		ID := createdTodo.ID
+
+		// Delete moves the todo into the trash
+		rows, err := todoService.Delete(ctx, ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), rows)
+
+		_, err = todoService.Get(ctx, ID)
+		assert.NotNil(t, err)
+
+		trashed, total, err := todoService.ListTrash(ctx, 1, 10)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, total)
+		assert.Equal(t, ID, trashed[0].ID)
+		assert.True(t, trashed[0].DeletedAt.Valid)
+
+		// Restore
+		restored, err := todoService.Restore(ctx, ID)
+		assert.Nil(t, err)
+		assert.Equal(t, ID, restored.ID)
+		assert.False(t, restored.DeletedAt.Valid)
+
+		_, err = todoService.Restore(ctx, ID)
+		assert.True(t, xerrors.Is(err, gorm.ErrRecordNotFound))
+
+		// Purge only trashed todos older than the retention
+		_, err = todoService.Delete(ctx, ID)
+		assert.Nil(t, err)
+
+		purged, err := todoService.PurgeTrash(ctx, time.Hour)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), purged)
+
+		purged, err = todoService.PurgeTrash(ctx, -time.Hour)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), purged)
+
+		_, total, err = todoService.ListTrash(ctx, 1, 10)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, total)
+	}))
+
+	t.Run("Patch", wrapper(func(t *testing.T) {
+		createdTodo, err := todoService.Create(ctx, &Todo{Task: "test task", Status: false})
+		assert.Nil(t, err)
+
+		task := "patched"
+		patchedTodo, err := todoService.Patch(ctx, createdTodo.ID, createdTodo.Version, &TodoPatch{Task: &task})
+		assert.Nil(t, err)
+		assert.Equal(t, "patched", patchedTodo.Task)
+		assert.Equal(t, createdTodo.Slug, patchedTodo.Slug)
+		assert.Equal(t, createdTodo.Status, patchedTodo.Status)
+		assert.Equal(t, createdTodo.Version+1, patchedTodo.Version)
+
+		// Stale version
+		_, err = todoService.Patch(ctx, createdTodo.ID, createdTodo.Version, &TodoPatch{Task: &task})
+		assert.True(t, xerrors.Is(err, ErrVersionConflict))
+
+		// Validation
+		empty := ""
+		_, err = todoService.Patch(ctx, createdTodo.ID, patchedTodo.Version, &TodoPatch{Task: &empty})
+		assert.True(t, xerrors.Is(err, ErrInvalidPatch))
+	}))
+
+	t.Run("Batch", wrapper(func(t *testing.T) {
+		results, err := todoService.BatchCreate(ctx, []*Todo{
+			{Task: "first"},
+			{Task: "second"},
+			{Task: "third"},
+		}, BatchModeAtomic)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(results))
+		for i, result := range results {
+			assert.Nil(t, result.Err)
+			assert.Equal(t, i, result.Index)
+			assert.NotZero(t, result.ID)
+		}
+		first, second := results[0].Todo, results[1].Todo
+
+		// Atomic: one missing todo rolls back the others
+		results, err = todoService.BatchDelete(ctx, []*BatchDeleteItem{{ID: first.ID}, {ID: 9999}}, BatchModeAtomic)
+		assert.NotNil(t, err)
+		assert.True(t, xerrors.Is(results[0].Err, ErrBatchRolledBack))
+		assert.True(t, xerrors.Is(results[1].Err, gorm.ErrRecordNotFound))
+		_, err = todoService.Get(ctx, first.ID)
+		assert.Nil(t, err)
+
+		// Partial: the others are committed
+		results, err = todoService.BatchDelete(ctx, []*BatchDeleteItem{{ID: first.ID}, {ID: 9999}}, BatchModePartial)
+		assert.Nil(t, err)
+		assert.Nil(t, results[0].Err)
+		assert.True(t, xerrors.Is(results[1].Err, gorm.ErrRecordNotFound))
+		_, err = todoService.Get(ctx, first.ID)
+		assert.NotNil(t, err)
+
+		// Stale version
+		results, err = todoService.BatchUpdate(ctx, []*Todo{
+			{ID: second.ID, Task: "changed"},
+			{ID: second.ID, Task: "stale", Version: second.Version},
+		}, BatchModePartial)
+		assert.Nil(t, err)
+		assert.Nil(t, results[0].Err)
+		assert.Equal(t, "changed", results[0].Todo.Task)
+		assert.True(t, xerrors.Is(results[1].Err, ErrVersionConflict))
+
+		// Changed slug fails like the single update does
+		results, err = todoService.BatchUpdate(ctx, []*Todo{
+			{ID: second.ID, Task: "renamed"},
+			{ID: second.ID, Slug: "changed-slug", Task: "renamed"},
+		}, BatchModeAtomic)
+		assert.True(t, xerrors.Is(err, ErrInvalidParameter))
+		assert.True(t, xerrors.Is(results[0].Err, ErrBatchRolledBack))
+		assert.True(t, xerrors.Is(results[1].Err, ErrInvalidParameter))
+
+		results, err = todoService.BatchUpdate(ctx, []*Todo{
+			{ID: second.ID, Task: "renamed"},
+			{ID: second.ID, Slug: "changed-slug", Task: "renamed"},
+		}, BatchModePartial)
+		assert.Nil(t, err)
+		assert.Nil(t, results[0].Err)
+		assert.True(t, xerrors.Is(results[1].Err, ErrInvalidParameter))
+		found, err := todoService.Get(ctx, second.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, second.Slug, found.Slug)
+
+		// Unknown mode
+		_, err = todoService.BatchCreate(ctx, []*Todo{{Task: "first"}}, "sometimes")
+		assert.True(t, xerrors.Is(err, ErrInvalidBatch))
+	}))
+
+	t.Run("List and CreateInBatches", wrapper(func(t *testing.T) {
 		batchAmount := 10
 
 		// Create Dummy Todo array
@@ -60,16 +253,152 @@ func TestTodoService(t *testing.T) {
 			todos = append(todos, todo)
 		}
 
-		_, err := todoService.CreateInBatches(todos)
+		_, err := todoService.CreateInBatches(ctx, todos)
 		assert.Nil(t, err)
 
-		results, rows, err := todoService.List(true, 1, 20, "updated_at asc")
+		results, rows, err := todoService.List(ctx, &TodoFilter{Status: &status}, 1, 20, updatedAtAsc)
 		assert.Nil(t, err)
 		assert.NotEmpty(t, results)
 		assert.Equal(t, batchAmount, rows)
+
+		// Total rows should not depend on the page size
+		results, rows, err = todoService.List(ctx, &TodoFilter{Status: &status}, 2, 3, updatedAtAsc)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(results))
+		assert.Equal(t, batchAmount, rows)
+	}))
+
+	t.Run("List pages rows which tie on the sort", wrapper(func(t *testing.T) {
+		todos := make([]Todo, 7)
+		for i := range todos {
+			todos[i] = Todo{Task: fmt.Sprintf("task %d", i), Status: true}
+		}
+		_, err := todoService.CreateInBatches(ctx, todos)
+		assert.Nil(t, err)
+
+		// Every row shows up once and in the same order on every store
+		var IDs []int64
+		for page := 1; page <= 4; page++ {
+			results, _, err := todoService.List(ctx, nil, page, 2, []SortOrder{{Column: "status", Desc: true}})
+			assert.Nil(t, err)
+			for _, result := range results {
+				IDs = append(IDs, result.ID)
+			}
+		}
+		assert.Len(t, IDs, len(todos))
+		assert.True(t, sort.SliceIsSorted(IDs, func(i, j int) bool { return IDs[i] > IDs[j] }), IDs)
+	}))
+
+	t.Run("ListByCursor", wrapper(func(t *testing.T) {
+		batchAmount := 10
+
+		// Create Dummy Todo array
+		var todos = []Todo{}
+		for i := 0; i < batchAmount; i++ {
+			todo := Todo{}
+			// Generate Fake data
+			if err := faker.FakeData(&todo); err != nil {
+				assert.Nil(t, err)
+			}
+			todo.Status = true
+			todos = append(todos, todo)
+		}
+
+		_, err := todoService.CreateInBatches(ctx, todos)
+		assert.Nil(t, err)
+
+		// Walk through all pages and make sure every row shows up only once
+		seen := map[int64]bool{}
+		cursor := ""
+		for pages := 0; pages < batchAmount; pages++ {
+			results, nextCursor, err := todoService.ListByCursor(ctx, &TodoFilter{Status: &status}, cursor, 3)
+			assert.Nil(t, err)
+			for _, result := range results {
+				assert.False(t, seen[result.ID])
+				seen[result.ID] = true
+			}
+			if nextCursor == "" {
+				break
+			}
+			cursor = nextCursor
+		}
+		assert.Equal(t, batchAmount, len(seen))
+
+		// Forged cursor
+		_, _, err = todoService.ListByCursor(ctx, &TodoFilter{Status: &status}, "forged.cursor", 3)
+		assert.NotNil(t, err)
+
+		// Cursor of another filter
+		_, nextCursor, err := todoService.ListByCursor(ctx, &TodoFilter{Status: &status}, "", 3)
+		assert.Nil(t, err)
+		_, _, err = todoService.ListByCursor(ctx, nil, nextCursor, 3)
+		assert.True(t, xerrors.Is(err, ErrInvalidCursor))
+	}))
+
+	t.Run("List with filter and sort", wrapper(func(t *testing.T) {
+		todos := []Todo{
+			{Slug: "alpha", Task: "buy 100% milk", Status: true},
+			{Slug: "beta", Task: "walk the dog", Status: true},
+			{Slug: "gamma", Task: "buy bread", Status: false},
+		}
+		_, err := todoService.CreateInBatches(ctx, todos)
+		assert.Nil(t, err)
+
+		// Task substring, wildcards match literally
+		results, rows, err := todoService.List(ctx, &TodoFilter{Task: "100%"}, 1, 20, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, rows)
+		assert.Equal(t, 1, len(results))
+
+		results, rows, err = todoService.List(ctx, &TodoFilter{Task: "buy"}, 1, 20, []SortOrder{{Column: "id", Desc: true}})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, rows)
+		assert.True(t, results[0].ID > results[1].ID)
+
+		// No filter
+		_, rows, err = todoService.List(ctx, nil, 1, 20, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, rows)
+
+		// Date range
+		future := time.Now().UTC().Add(time.Hour)
+		_, rows, err = todoService.List(ctx, &TodoFilter{CreatedFrom: &future}, 1, 20, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, rows)
+
+		// Not whitelisted column
+		_, _, err = todoService.List(ctx, nil, 1, 20, []SortOrder{{Column: "task; DROP TABLE todos"}})
+		assert.True(t, xerrors.Is(err, ErrInvalidQuery))
+	}))
+
+	t.Run("Search", wrapper(func(t *testing.T) {
+		todos := []Todo{
+			{Task: "buy fresh milk at the market", Status: true},
+			{Task: "milk tea recipe", Status: true},
+			{Task: "walk the dog", Status: false},
+		}
+		_, err := todoService.CreateInBatches(ctx, todos)
+		assert.Nil(t, err)
+
+		results, rows, err := todoService.Search(ctx, "milk", &SearchOptions{Page: 1, PageSize: 10})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, rows)
+		assert.Equal(t, 2, len(results))
+		assert.Contains(t, results[0].Snippet, "<mark>milk</mark>")
+		assert.True(t, 0 < results[0].Score)
+
+		// Boolean mode
+		results, rows, err = todoService.Search(ctx, "+milk -tea", &SearchOptions{Mode: SearchModeBoolean, Page: 1, PageSize: 10})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, rows)
+		assert.Equal(t, "buy fresh milk at the market", results[0].Task)
+
+		// Unknown mode
+		_, _, err = todoService.Search(ctx, "milk", &SearchOptions{Mode: "regexp", PageSize: 10})
+		assert.True(t, xerrors.Is(err, ErrInvalidSearch))
 	}))
 
-	t.Run("List Random Count True", eachTestWrapper(func(t *testing.T) {
+	t.Run("List Random Count True", wrapper(func(t *testing.T) {
 		batchAmount := 10
 
 		// Create Dummy Todo array
@@ -93,16 +422,16 @@ func TestTodoService(t *testing.T) {
 			todos = append(todos, todo)
 		}
 
-		_, err := todoService.CreateInBatches(todos)
+		_, err := todoService.CreateInBatches(ctx, todos)
 		assert.Nil(t, err)
 
-		results, rows, err := todoService.List(true, 1, 20, "updated_at asc")
+		results, rows, err := todoService.List(ctx, &TodoFilter{Status: &status}, 1, 20, updatedAtAsc)
 		assert.Nil(t, err)
 		assert.NotEmpty(t, results)
 		assert.Equal(t, trueAmount, rows)
 	}))
 
-	t.Run("Update", eachTestWrapper(func(t *testing.T) {
+	t.Run("Update", wrapper(func(t *testing.T) {
 		batchAmount := 10
 
 		// Create Dummy Todo array
@@ -119,20 +448,37 @@ func TestTodoService(t *testing.T) {
 			todos = append(todos, todo)
 		}
 
-		createdTodos, err := todoService.CreateInBatches(todos)
+		createdTodos, err := todoService.CreateInBatches(ctx, todos)
 		assert.Nil(t, err)
 
 		updateTodo := createdTodos[0]
 		updateTodo.Status = false
 		updateTodo.Task = "Changed"
-		retTodo, err := todoService.Update(&updateTodo)
+		retTodo, err := todoService.Update(ctx, &updateTodo)
 		assert.NotNil(t, retTodo)
 
-		results, err := todoService.Get(retTodo.ID)
+		results, err := todoService.Get(ctx, retTodo.ID)
 		assert.Nil(t, err)
 		assert.NotEmpty(t, results)
 		assert.Equal(t, false, results.Status)
 		assert.Equal(t, "Changed", results.Task)
+		assert.Equal(t, updateTodo.Version+1, results.Version)
+
+		// Stale version
+		updateTodo.Task = "Changed again"
+		_, err = todoService.Update(ctx, &updateTodo)
+		assert.True(t, xerrors.Is(err, ErrVersionConflict))
+
+		_, err = todoService.DeleteVersion(ctx, updateTodo.ID, updateTodo.Version)
+		assert.True(t, xerrors.Is(err, ErrVersionConflict))
+
+		rows, err := todoService.DeleteVersion(ctx, results.ID, results.Version)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), rows)
+
+		// Not found is not a conflict
+		_, err = todoService.Update(ctx, results)
+		assert.True(t, xerrors.Is(err, gorm.ErrRecordNotFound))
 	}))
 
 }
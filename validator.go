@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/xerrors"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Used by the validate tags through the slug_length and task_length aliases
+const (
+	// Same as the slug column, VARCHAR(50)
+	SlugMaxLength = 50
+	// Limit of the API, not of the column. MEDIUMTEXT of MySQL holds 16 MiB and TEXT of
+	// PostgreSQL and SQLite has no limit, this keeps requests and the full-text index small.
+	TaskMaxLength = 16383
+)
+
+var (
+	// Letters, digits, hyphens and underscores, which the generated UUID slugs consist of as well
+	slugPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+	// Safe for concurrent use, caches the parsed struct tags
+	validate = newValidate()
+)
+
+type (
+	// Echo Validator called through echo.Context#Validate
+	// https://echo.labstack.com/guide/request/#validate-data
+	structValidator struct{}
+)
+
+func NewValidator() echo.Validator {
+	return &structValidator{}
+}
+
+func (v *structValidator) Validate(i interface{}) error {
+	if err := validate.Struct(i); err != nil {
+		return xerrors.Errorf("Validate : %+w", validationError(ErrValidation, err))
+	}
+	return nil
+}
+
+// https://pkg.go.dev/github.com/go-playground/validator/v10
+func newValidate() *validator.Validate {
+	v := validator.New()
+
+	// Report the fields by their JSON names
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	if err := v.RegisterValidation("slug", func(fl validator.FieldLevel) bool {
+		return slugPattern.MatchString(fl.Field().String())
+	}); err != nil {
+		panic(err)
+	}
+
+	// Reported as the max rule they stand for
+	v.RegisterAlias("slug_length", fmt.Sprintf("max=%d", SlugMaxLength))
+	v.RegisterAlias("task_length", fmt.Sprintf("max=%d", TaskMaxLength))
+
+	return v
+}
+
+// Turn validator.ValidationErrors into the domain error listing the invalid fields.
+// Any other error means the validator is misused and is returned as it is.
+func validationError(base *DomainError, err error) error {
+	var errs validator.ValidationErrors
+	if !xerrors.As(err, &errs) {
+		return err
+	}
+
+	fields := make([]*FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, &FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.ActualTag(),
+			Message: fieldMessage(fe),
+		})
+	}
+	return base.WithFields(fields).Wrap(err)
+}
+
+// Namespace without the struct name, such as items[0].task
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); 0 <= i {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.ActualTag() {
+	case "required":
+		return fmt.Sprintf("%s is required", fe.Field())
+	case "max":
+		return fmt.Sprintf("%s must be %s characters or less", fe.Field(), fe.Param())
+	case "min":
+		return fmt.Sprintf("%s must be %s or more", fe.Field(), fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", fe.Field(), strings.Join(strings.Fields(fe.Param()), ", "))
+	case "slug":
+		return fmt.Sprintf("%s must consist of letters, digits, hyphens and underscores", fe.Field())
+	}
+	return fmt.Sprintf("%s is invalid", fe.Field())
+}
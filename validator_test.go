@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidator(t *testing.T) {
+	t.Parallel()
+	v := NewValidator()
+
+	t.Run("Todo", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Nil(t, v.Validate(&Todo{Slug: "test-slug_1", Task: "task"}))
+		// The slug is generated when it's not given
+		assert.Nil(t, v.Validate(&Todo{Task: "task"}))
+
+		err := v.Validate(&Todo{Slug: strings.Repeat("a", SlugMaxLength+1)})
+		assert.True(t, xerrors.Is(err, ErrValidation))
+		assert.Equal(t, http.StatusUnprocessableEntity, ErrorStatus(err))
+
+		var domainErr *DomainError
+		assert.True(t, xerrors.As(err, &domainErr))
+		assert.Len(t, domainErr.Fields, 2)
+		assert.Equal(t, "slug", domainErr.Fields[0].Field)
+		assert.Equal(t, "max", domainErr.Fields[0].Rule)
+		assert.Equal(t, "task", domainErr.Fields[1].Field)
+		assert.Equal(t, "required", domainErr.Fields[1].Rule)
+
+		err = v.Validate(&Todo{Task: strings.Repeat("a", TaskMaxLength+1)})
+		assert.True(t, xerrors.As(err, &domainErr))
+		assert.Equal(t, "max", domainErr.Fields[0].Rule)
+		assert.Equal(t, fmt.Sprintf("task must be %d characters or less", TaskMaxLength), domainErr.Fields[0].Message)
+
+		err = v.Validate(&Todo{Slug: "no spaces", Task: "task"})
+		assert.True(t, xerrors.As(err, &domainErr))
+		assert.Equal(t, "slug", domainErr.Fields[0].Rule)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		t.Parallel()
+
+		err := v.Validate(&TodoBatchRequest{
+			Mode:  "sometimes",
+			Items: []*Todo{{Task: "first"}, {Task: ""}},
+		})
+
+		var domainErr *DomainError
+		assert.True(t, xerrors.As(err, &domainErr))
+		assert.Len(t, domainErr.Fields, 2)
+		assert.Equal(t, "mode", domainErr.Fields[0].Field)
+		assert.Equal(t, "items[1].task", domainErr.Fields[1].Field)
+
+		err = v.Validate(&TodoBatchDeleteRequest{Items: []*BatchDeleteItem{{ID: 1}, {ID: 0}}})
+		assert.True(t, xerrors.As(err, &domainErr))
+		assert.Equal(t, "items[1].id", domainErr.Fields[0].Field)
+	})
+
+	t.Run("Problem lists the fields", func(t *testing.T) {
+		t.Parallel()
+
+		problem := NewProblem(v.Validate(&Todo{}))
+		assert.Equal(t, http.StatusUnprocessableEntity, problem.Status)
+		assert.Equal(t, ErrValidation.Code, problem.Code)
+		assert.Len(t, problem.Errors, 1)
+		assert.Equal(t, "task is required", problem.Errors[0].Message)
+	})
+}